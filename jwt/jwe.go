@@ -0,0 +1,258 @@
+package jwt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"hash"
+	"strings"
+)
+
+// JWE(RFC 7516)支持的密钥管理算法和内容加密算法
+const (
+	JWE_ALG_DIR          = "dir"          // 直接使用共享的对称密钥
+	JWE_ALG_RSA_OAEP     = "RSA-OAEP"     // RSAES-OAEP，SHA-1
+	JWE_ALG_RSA_OAEP_256 = "RSA-OAEP-256" // RSAES-OAEP，SHA-256
+
+	JWE_ENC_A128GCM = "A128GCM"
+	JWE_ENC_A192GCM = "A192GCM"
+	JWE_ENC_A256GCM = "A256GCM"
+)
+
+// 加密相关的错误
+var (
+	ErrUnsupportedEncryption = errors.New("unsupported jwe algorithm or encryption")
+	ErrInvalidEncryptionKey  = errors.New("invalid jwe encryption key")
+)
+
+// EncryptionKey JWE加密密钥。JwtSign设置了Encryption时，CreateToken先签名再加密，生成的token客户端无法读取claims
+type EncryptionKey struct {
+	ID         string          // kid，写入JWE header
+	Algorithm  string          // JWE_ALG_DIR、JWE_ALG_RSA_OAEP或JWE_ALG_RSA_OAEP_256
+	Encryption string          // JWE_ENC_A256GCM等
+	Secret     []byte          // dir使用的对称密钥，长度需与Encryption一致(A256GCM为32字节)
+	PrivateKey *rsa.PrivateKey // RSA-OAEP解密用，仅加密时可为nil
+	PublicKey  *rsa.PublicKey  // RSA-OAEP加密用
+}
+
+// jweHeader JWE的protected header
+type jweHeader struct {
+	Alg string `json:"alg"`
+	Enc string `json:"enc"`
+	Kid string `json:"kid,omitempty"`
+	Cty string `json:"cty,omitempty"`
+}
+
+// NewDirectEncryptionKey 新建一个dir+A256GCM的加密密钥，secret需为32字节
+func NewDirectEncryptionKey(kid string, secret []byte) (*EncryptionKey, error) {
+	if len(secret) != 32 {
+		return nil, ErrInvalidEncryptionKey
+	}
+	return &EncryptionKey{
+		ID:         kid,
+		Algorithm:  JWE_ALG_DIR,
+		Encryption: JWE_ENC_A256GCM,
+		Secret:     secret,
+	}, nil
+}
+
+// NewRSAEncryptionKey 新建一个RSA-OAEP+A256GCM的加密密钥，algorithm为JWE_ALG_RSA_OAEP或JWE_ALG_RSA_OAEP_256
+func NewRSAEncryptionKey(kid, algorithm string, privateKey *rsa.PrivateKey) (*EncryptionKey, error) {
+	if algorithm != JWE_ALG_RSA_OAEP && algorithm != JWE_ALG_RSA_OAEP_256 {
+		return nil, ErrUnsupportedEncryption
+	}
+	return &EncryptionKey{
+		ID:         kid,
+		Algorithm:  algorithm,
+		Encryption: JWE_ENC_A256GCM,
+		PrivateKey: privateKey,
+		PublicKey:  &privateKey.PublicKey,
+	}, nil
+}
+
+// Encrypt 将payload加密为compact格式的JWE，cty为内容类型，嵌套JWT时为"JWT"
+func (k *EncryptionKey) Encrypt(payload []byte, cty string) (string, error) {
+	keySize, err := encKeySize(k.Encryption)
+	if err != nil {
+		return "", err
+	}
+
+	var cek, encryptedKey []byte
+	switch k.Algorithm {
+	case JWE_ALG_DIR:
+		if len(k.Secret) != keySize {
+			return "", ErrInvalidEncryptionKey
+		}
+		cek = k.Secret
+	case JWE_ALG_RSA_OAEP, JWE_ALG_RSA_OAEP_256:
+		if k.PublicKey == nil {
+			return "", ErrInvalidEncryptionKey
+		}
+		cek = make([]byte, keySize)
+		if _, err := rand.Read(cek); err != nil {
+			return "", err
+		}
+		if encryptedKey, err = rsa.EncryptOAEP(oaepHash(k.Algorithm), rand.Reader, k.PublicKey, cek, nil); err != nil {
+			return "", err
+		}
+	default:
+		return "", ErrUnsupportedEncryption
+	}
+
+	header, err := json.Marshal(jweHeader{Alg: k.Algorithm, Enc: k.Encryption, Kid: k.ID, Cty: cty})
+	if err != nil {
+		return "", err
+	}
+	protected := base64.RawURLEncoding.EncodeToString(header)
+
+	gcm, err := newGCM(cek)
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+	// Seal的结果为 ciphertext || tag，additional data为protected header (RFC 7516 5.1)
+	sealed := gcm.Seal(nil, iv, payload, []byte(protected))
+	ciphertext, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+
+	return strings.Join([]string{
+		protected,
+		base64.RawURLEncoding.EncodeToString(encryptedKey),
+		base64.RawURLEncoding.EncodeToString(iv),
+		base64.RawURLEncoding.EncodeToString(ciphertext),
+		base64.RawURLEncoding.EncodeToString(tag),
+	}, "."), nil
+}
+
+// Decrypt 解密compact格式的JWE。header中的alg、enc必须与密钥一致；格式错误返回TokenMalformed，无法解密返回TokenInvalid
+func (k *EncryptionKey) Decrypt(token string) ([]byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 {
+		return nil, TokenMalformed
+	}
+	segments := make([][]byte, 5)
+	for i, part := range parts {
+		segment, err := base64.RawURLEncoding.DecodeString(part)
+		if err != nil {
+			return nil, TokenMalformed
+		}
+		segments[i] = segment
+	}
+	var header jweHeader
+	if err := json.Unmarshal(segments[0], &header); err != nil {
+		return nil, TokenMalformed
+	}
+	if header.Alg != k.Algorithm || header.Enc != k.Encryption {
+		return nil, TokenInvalid
+	}
+	if header.Kid != "" && k.ID != "" && header.Kid != k.ID {
+		return nil, TokenInvalid
+	}
+	keySize, err := encKeySize(k.Encryption)
+	if err != nil {
+		return nil, err
+	}
+
+	var cek []byte
+	switch k.Algorithm {
+	case JWE_ALG_DIR:
+		if len(segments[1]) != 0 {
+			return nil, TokenInvalid
+		}
+		cek = k.Secret
+	case JWE_ALG_RSA_OAEP, JWE_ALG_RSA_OAEP_256:
+		if k.PrivateKey == nil {
+			return nil, ErrInvalidEncryptionKey
+		}
+		cek, err = rsa.DecryptOAEP(oaepHash(k.Algorithm), nil, k.PrivateKey, segments[1], nil)
+		if err != nil || len(cek) != keySize {
+			// 解密失败时使用随机的CEK继续，不暴露是哪一步失败的 (RFC 7516 11.5)
+			cek = make([]byte, keySize)
+			if _, err := rand.Read(cek); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, ErrUnsupportedEncryption
+	}
+
+	gcm, err := newGCM(cek)
+	if err != nil {
+		return nil, TokenInvalid
+	}
+	if len(segments[2]) != gcm.NonceSize() || len(segments[4]) != gcm.Overhead() {
+		return nil, TokenMalformed
+	}
+	payload, err := gcm.Open(nil, segments[2], append(segments[3], segments[4]...), []byte(parts[0]))
+	if err != nil {
+		return nil, TokenInvalid
+	}
+	return payload, nil
+}
+
+// isJWE compact格式的JWE有5段，JWS有3段
+func isJWE(token string) bool {
+	return strings.Count(token, ".") == 4
+}
+
+// encrypt 设置了Encryption时，将签名后的token加密
+func (j *JwtSign) encrypt(signed string) (string, error) {
+	if j.Encryption == nil {
+		return signed, nil
+	}
+	return j.Encryption.Encrypt([]byte(signed), "JWT")
+}
+
+// decrypt 如果是JWE，解密得到其中签名的token；不是JWE则原样返回
+func (j *JwtSign) decrypt(tokenString string) (string, error) {
+	if !isJWE(tokenString) {
+		return tokenString, nil
+	}
+	if j.Encryption == nil {
+		return "", TokenMalformed
+	}
+	payload, err := j.Encryption.Decrypt(tokenString)
+	if err != nil {
+		return "", err
+	}
+	return string(payload), nil
+}
+
+// encKeySize 内容加密算法所需的密钥长度
+func encKeySize(enc string) (int, error) {
+	switch enc {
+	case JWE_ENC_A128GCM:
+		return 16, nil
+	case JWE_ENC_A192GCM:
+		return 24, nil
+	case JWE_ENC_A256GCM:
+		return 32, nil
+	default:
+		return 0, ErrUnsupportedEncryption
+	}
+}
+
+// oaepHash RSA-OAEP使用SHA-1，RSA-OAEP-256使用SHA-256
+func oaepHash(alg string) hash.Hash {
+	if alg == JWE_ALG_RSA_OAEP_256 {
+		return sha256.New()
+	}
+	return sha1.New()
+}
+
+// newGCM 使用CEK新建AES-GCM
+func newGCM(cek []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,105 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adamesong/go-util/random"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptedToken(t *testing.T) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	require.NoError(t, err)
+	dirKey, err := NewDirectEncryptionKey("dir", secret)
+	require.NoError(t, err)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	oaepKey, err := NewRSAEncryptionKey("oaep", JWE_ALG_RSA_OAEP, rsaKey)
+	require.NoError(t, err)
+	oaep256Key, err := NewRSAEncryptionKey("oaep256", JWE_ALG_RSA_OAEP_256, rsaKey)
+	require.NoError(t, err)
+
+	for _, encryptionKey := range []*EncryptionKey{dirKey, oaepKey, oaep256Key} {
+		t.Run(encryptionKey.Algorithm, func(t *testing.T) {
+			assertion := assert.New(t)
+			jwtSign := NewJwtSign(random.RandomString(12))
+			jwtSign.Encryption = encryptionKey
+
+			claims := newTestClaims()
+			token, err := jwtSign.CreateToken(claims)
+			require.NoError(t, err)
+			assertion.Equal(5, len(strings.Split(token, ".")))
+
+			parsed, err := jwtSign.ParseToken(token)
+			require.NoError(t, err)
+			assertion.Equal(claims.UserID, parsed.UserID)
+
+			// 篡改密文后无法解密
+			parts := strings.Split(token, ".")
+			if parts[3][0] == 'A' {
+				parts[3] = "B" + parts[3][1:]
+			} else {
+				parts[3] = "A" + parts[3][1:]
+			}
+			_, err = jwtSign.ParseToken(strings.Join(parts, "."))
+			assertion.Equal(TokenInvalid, err)
+
+			// 过期的token仍然返回TokenExpired
+			claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+			token, err = jwtSign.CreateToken(claims)
+			require.NoError(t, err)
+			_, err = jwtSign.ParseToken(token)
+			assertion.Equal(TokenExpired, err)
+			expired, err := jwtSign.GetClaimsFromExpiredToken(token)
+			require.NoError(t, err)
+			assertion.Equal(claims.UserID, expired.UserID)
+		})
+	}
+}
+
+func TestEncryptedTokenWithoutKey(t *testing.T) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	require.NoError(t, err)
+	encryptionKey, err := NewDirectEncryptionKey("dir", secret)
+	require.NoError(t, err)
+
+	signingSecret := random.RandomString(12)
+	jwtSign := NewJwtSign(signingSecret)
+	jwtSign.Encryption = encryptionKey
+	token, err := jwtSign.CreateToken(newTestClaims())
+	require.NoError(t, err)
+
+	// 签名密钥相同但没有加密密钥
+	_, err = NewJwtSign(signingSecret).ParseToken(token)
+	assert.Equal(t, TokenMalformed, err)
+
+	// 加密密钥不同
+	otherSecret := make([]byte, 32)
+	_, err = rand.Read(otherSecret)
+	require.NoError(t, err)
+	other := NewJwtSign(signingSecret)
+	other.Encryption, err = NewDirectEncryptionKey("dir", otherSecret)
+	require.NoError(t, err)
+	_, err = other.ParseToken(token)
+	assert.Equal(t, TokenInvalid, err)
+
+	// 加密算法不符
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	other.Encryption, err = NewRSAEncryptionKey("dir", JWE_ALG_RSA_OAEP, rsaKey)
+	require.NoError(t, err)
+	_, err = other.ParseToken(token)
+	assert.Equal(t, TokenInvalid, err)
+
+	_, err = NewDirectEncryptionKey("dir", []byte("too short"))
+	assert.Equal(t, ErrInvalidEncryptionKey, err)
+}
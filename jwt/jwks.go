@@ -0,0 +1,356 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/sync/singleflight"
+)
+
+// JWKS相关的默认值
+const (
+	DEFAULT_JWKS_CACHE_DURATION   = time.Hour        // 远程JWKS的缓存时间
+	DEFAULT_JWKS_REFRESH_INTERVAL = time.Minute      // 遇到未知kid时，两次刷新远程JWKS之间的最短间隔
+	DEFAULT_JWKS_TIMEOUT          = 10 * time.Second // 获取远程JWKS的超时时间
+	DEFAULT_JWKS_MAX_AGE          = 300              // JWKSHandler返回的Cache-Control max-age，秒
+)
+
+// JWK JSON Web Key (RFC 7517)，仅包含公钥部分
+type JWK struct {
+	Kty string `json:"kty"`           // RSA, EC, OKP
+	Kid string `json:"kid,omitempty"` // key id
+	Use string `json:"use,omitempty"` // sig
+	Alg string `json:"alg,omitempty"` // RS256, ES256, EdDSA...
+	N   string `json:"n,omitempty"`   // RSA modulus
+	E   string `json:"e,omitempty"`   // RSA exponent
+	Crv string `json:"crv,omitempty"` // P-256, P-384, P-521, Ed25519
+	X   string `json:"x,omitempty"`   // EC/OKP x坐标
+	Y   string `json:"y,omitempty"`   // EC y坐标
+}
+
+// JWKS JSON Web Key Set，即 /.well-known/jwks.json 的内容
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// KeySource 按kid提供验证密钥，JwtSign在本地密钥环中找不到kid时使用，例如RemoteJWKS
+type KeySource interface {
+	Key(kid string) (*Key, error)
+}
+
+// NewJWK 将一个密钥的公钥部分转为JWK。HMAC密钥不能公开，返回ErrUnsupportedKey
+func NewJWK(key *Key) (JWK, error) {
+	jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Method.Alg()}
+	switch publicKey := key.PublicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (publicKey.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = publicKey.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(publicKey.X.FillBytes(make([]byte, size)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(publicKey.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+	default:
+		return JWK{}, ErrUnsupportedKey
+	}
+	return jwk, nil
+}
+
+// Key 将JWK转为仅用于验证的密钥
+func (k JWK) Key() (*Key, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid jwk n: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid jwk e: %w", err)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, ErrUnsupportedKey
+		}
+		key := NewRSAPublicKey(k.Kid, &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())})
+		return key.withAlg(k.Alg)
+	case "EC":
+		curve, ecdhCurve, err := jwkCurve(k.Crv)
+		if err != nil {
+			return nil, err
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid jwk x: %w", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid jwk y: %w", err)
+		}
+		// 借助crypto/ecdh确认该点在曲线上
+		if _, err := ecdhCurve.NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return nil, fmt.Errorf("invalid jwk point: %w", err)
+		}
+		return NewECDSAPublicKey(k.Kid, &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		})
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, ErrUnsupportedKey
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid jwk x: %w", ErrUnsupportedKey)
+		}
+		return NewEdDSAPublicKey(k.Kid, ed25519.PublicKey(x)), nil
+	default:
+		return nil, ErrUnsupportedKey
+	}
+}
+
+// withAlg RSA密钥可能使用RS256/RS384/RS512或PS256/PS384/PS512，以JWK中的alg为准，其他alg返回ErrUnsupportedKey
+func (k *Key) withAlg(alg string) (*Key, error) {
+	if alg == "" {
+		return k, nil
+	}
+	var method jwt.SigningMethod
+	switch alg {
+	case "RS256", "RS384", "RS512", "PS256", "PS384", "PS512":
+		method = jwt.GetSigningMethod(alg)
+	}
+	if method == nil {
+		return nil, ErrUnsupportedKey
+	}
+	k.Method = method
+	return k, nil
+}
+
+// jwkCurve 根据JWK中的crv得到椭圆曲线
+func jwkCurve(crv string) (elliptic.Curve, ecdh.Curve, error) {
+	switch crv {
+	case "P-256":
+		return elliptic.P256(), ecdh.P256(), nil
+	case "P-384":
+		return elliptic.P384(), ecdh.P384(), nil
+	case "P-521":
+		return elliptic.P521(), ecdh.P521(), nil
+	default:
+		return nil, nil, ErrUnsupportedKey
+	}
+}
+
+// JWKS 获取密钥环中所有非对称密钥的公钥，按kid排序。HMAC密钥不会出现在结果中
+func (j *JwtSign) JWKS() JWKS {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	set := JWKS{Keys: make([]JWK, 0, len(j.keys))}
+	for _, key := range j.keys {
+		if jwk, err := NewJWK(key); err == nil {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	sort.Slice(set.Keys, func(a, b int) bool {
+		return set.Keys[a].Kid < set.Keys[b].Kid
+	})
+	return set
+}
+
+// JWKSHandler 返回用于发布公钥的http.Handler，一般挂在 /.well-known/jwks.json
+// 每次请求时读取当前密钥环，所以轮换密钥后无需重新注册
+func (j *JwtSign) JWKSHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		data, err := json.Marshal(j.JWKS())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", DEFAULT_JWKS_MAX_AGE))
+		_, _ = w.Write(data)
+	})
+}
+
+// RemoteJWKS 从远程地址获取并缓存JWKS，用于验证其他服务签发的token。
+// 缓存过期后重新获取；遇到未知的kid时(对方可能轮换了密钥)，也会重新获取，但两次获取之间至少间隔RefreshInterval。
+// 获取在锁外进行，同时只有一个请求在获取，其他请求等待其结果；获取失败时继续使用已缓存的密钥
+type RemoteJWKS struct {
+	URL             string        // 例如 https://auth.example.com/.well-known/jwks.json
+	HTTPClient      *http.Client  // 为nil时使用http.DefaultClient
+	Timeout         time.Duration // 每次获取的超时时间，为0时使用DEFAULT_JWKS_TIMEOUT
+	CacheDuration   time.Duration // 缓存时间，为0时使用DEFAULT_JWKS_CACHE_DURATION
+	RefreshInterval time.Duration // 遇到未知kid时的最短刷新间隔，为0时使用DEFAULT_JWKS_REFRESH_INTERVAL，小于0时不限制
+
+	group       singleflight.Group
+	mu          sync.RWMutex
+	keys        map[string]*Key
+	fetchedAt   time.Time // 上次成功获取的时间
+	attemptedAt time.Time // 上次尝试获取的时间
+	lastErr     error     // 上次获取的错误
+}
+
+// NewRemoteJWKS 新建一个RemoteJWKS，使用默认的缓存时间和刷新间隔
+func NewRemoteJWKS(url string) *RemoteJWKS {
+	return &RemoteJWKS{
+		URL:             url,
+		CacheDuration:   DEFAULT_JWKS_CACHE_DURATION,
+		RefreshInterval: DEFAULT_JWKS_REFRESH_INTERVAL,
+	}
+}
+
+// NewJwtVerifier 新建一个仅用于验证的JwtSign，密钥全部来自source，例如RemoteJWKS
+func NewJwtVerifier(source KeySource) *JwtSign {
+	return &JwtSign{
+		KeySource: source,
+	}
+}
+
+// Key 根据kid获取密钥，实现KeySource
+func (r *RemoteJWKS) Key(kid string) (*Key, error) {
+	cacheDuration := r.CacheDuration
+	if cacheDuration == 0 {
+		cacheDuration = DEFAULT_JWKS_CACHE_DURATION
+	}
+	r.mu.RLock()
+	stale := r.keys == nil || time.Since(r.fetchedAt) > cacheDuration
+	r.mu.RUnlock()
+	if stale {
+		// 获取失败时，如有旧的缓存则继续使用
+		if err := r.refreshLimited(); err != nil && !r.cached() {
+			return nil, err
+		}
+	}
+	if key, ok := r.lookup(kid); ok {
+		return key, nil
+	}
+	// 未知的kid，对方可能已轮换密钥
+	if err := r.refreshLimited(); err != nil {
+		return nil, err
+	}
+	if key, ok := r.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, ErrKeyNotFound
+}
+
+// Refresh 立即重新获取远程JWKS，不受RefreshInterval限制
+func (r *RemoteJWKS) Refresh() error {
+	return r.refresh()
+}
+
+func (r *RemoteJWKS) lookup(kid string) (*Key, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	key, ok := r.keys[kid]
+	return key, ok
+}
+
+func (r *RemoteJWKS) cached() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.keys != nil
+}
+
+// refreshLimited 距离上次获取超过RefreshInterval时才重新获取，否则返回上次获取的结果，避免未知kid的请求打爆远程服务
+func (r *RemoteJWKS) refreshLimited() error {
+	refreshInterval := r.RefreshInterval
+	if refreshInterval == 0 {
+		refreshInterval = DEFAULT_JWKS_REFRESH_INTERVAL
+	}
+	r.mu.RLock()
+	limited := !r.attemptedAt.IsZero() && time.Since(r.attemptedAt) < refreshInterval
+	lastErr := r.lastErr
+	r.mu.RUnlock()
+	if limited {
+		return lastErr
+	}
+	return r.refresh()
+}
+
+// refresh 获取远程JWKS，成功时替换缓存，并记录本次获取的时间和结果。并发调用共享同一次获取
+func (r *RemoteJWKS) refresh() error {
+	_, err, _ := r.group.Do("jwks", func() (any, error) {
+		keys, err := r.fetch()
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.attemptedAt = time.Now()
+		r.lastErr = err
+		if err == nil {
+			r.keys = keys
+			r.fetchedAt = r.attemptedAt
+		}
+		return nil, err
+	})
+	return err
+}
+
+// fetch 获取远程JWKS，无法识别的密钥会被忽略
+func (r *RemoteJWKS) fetch() (map[string]*Key, error) {
+	client := r.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	timeout := r.Timeout
+	if timeout == 0 {
+		timeout = DEFAULT_JWKS_TIMEOUT
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetch jwks failed: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch jwks failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch jwks failed: %s", resp.Status)
+	}
+	var set JWKS
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("decode jwks failed: %w", err)
+	}
+	keys := make(map[string]*Key, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.Key()
+		if err != nil {
+			continue
+		}
+		keys[key.ID] = key
+	}
+	if len(keys) == 0 && len(set.Keys) > 0 {
+		return nil, errors.New("jwks contains no usable keys")
+	}
+	return keys, nil
+}
@@ -0,0 +1,178 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRSAKey(t *testing.T, kid string) *Key {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return NewRSAKey(kid, privateKey)
+}
+
+func TestJWKRoundTrip(t *testing.T) {
+	assertion := assert.New(t)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	esKey, err := NewECDSAKey("es", ecKey)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	for _, key := range []*Key{newTestRSAKey(t, "rs"), esKey, NewEdDSAKey("ed", edKey)} {
+		jwk, err := NewJWK(key)
+		require.NoError(t, err)
+		parsed, err := jwk.Key()
+		require.NoError(t, err)
+		assertion.Equal(key.ID, parsed.ID)
+		assertion.Equal(key.Method.Alg(), parsed.Method.Alg())
+		assertion.Equal(key.PublicKey, parsed.PublicKey)
+		assertion.False(parsed.CanSign())
+	}
+
+	// HMAC密钥不能发布
+	_, err = NewJWK(NewHMACKey("hs", []byte("secret")))
+	assertion.Equal(ErrUnsupportedKey, err)
+}
+
+func TestJWKSHandler(t *testing.T) {
+	jwtSign, err := NewJwtSignWithKeys("rs", newTestRSAKey(t, "rs"), NewHMACKey("hs", []byte("secret")))
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	jwtSign.JWKSHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+
+	var set JWKS
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &set))
+	require.Len(t, set.Keys, 1)
+	assert.Equal(t, "rs", set.Keys[0].Kid)
+	assert.Equal(t, "RSA", set.Keys[0].Kty)
+
+	recorder = httptest.NewRecorder()
+	jwtSign.JWKSHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/.well-known/jwks.json", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
+
+func TestRemoteJWKS(t *testing.T) {
+	assertion := assert.New(t)
+
+	issuer, err := NewJwtSignWithKeys("k1", newTestRSAKey(t, "k1"))
+	require.NoError(t, err)
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		issuer.JWKSHandler().ServeHTTP(w, req)
+	}))
+	defer server.Close()
+
+	remote := NewRemoteJWKS(server.URL)
+	verifier := NewJwtVerifier(remote)
+
+	claim := newTestClaims()
+	token, err := issuer.CreateToken(claim)
+	require.NoError(t, err)
+	parsedClaim, err := verifier.ParseToken(token)
+	require.NoError(t, err)
+	assertion.Equal(claim.UserID, parsedClaim.UserID)
+
+	// 缓存期内不会重复获取
+	_, err = verifier.ParseToken(token)
+	require.NoError(t, err)
+	assertion.Equal(int32(1), atomic.LoadInt32(&fetches))
+
+	// 签发方轮换密钥，遇到未知kid时重新获取
+	require.NoError(t, issuer.AddKey(newTestRSAKey(t, "k2")))
+	require.NoError(t, issuer.SetActiveKey("k2"))
+	newToken, err := issuer.CreateToken(claim)
+	require.NoError(t, err)
+
+	// 刷新间隔内不会重新获取
+	_, err = verifier.ParseToken(newToken)
+	assertion.Equal(TokenInvalid, err)
+	assertion.Equal(int32(1), atomic.LoadInt32(&fetches))
+
+	remote.RefreshInterval = 0 // 0为默认间隔，仍然受限
+	_, err = verifier.ParseToken(newToken)
+	assertion.Equal(TokenInvalid, err)
+	assertion.Equal(int32(1), atomic.LoadInt32(&fetches))
+
+	remote.RefreshInterval = -1
+	_, err = verifier.ParseToken(newToken)
+	assertion.Nil(err)
+	assertion.Equal(int32(2), atomic.LoadInt32(&fetches))
+
+	// 未知签发方的token不能通过验证
+	other, err := NewJwtSignWithKeys("k3", newTestRSAKey(t, "k3"))
+	require.NoError(t, err)
+	otherToken, err := other.CreateToken(claim)
+	require.NoError(t, err)
+	_, err = verifier.ParseToken(otherToken)
+	assertion.Equal(TokenInvalid, err)
+}
+
+func TestRemoteJWKSFailure(t *testing.T) {
+	assertion := assert.New(t)
+
+	issuer, err := NewJwtSignWithKeys("k1", newTestRSAKey(t, "k1"))
+	require.NoError(t, err)
+	var hang atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if hang.Load() {
+			<-req.Context().Done()
+			return
+		}
+		issuer.JWKSHandler().ServeHTTP(w, req)
+	}))
+	defer server.Close()
+
+	remote := &RemoteJWKS{URL: server.URL, Timeout: 100 * time.Millisecond, CacheDuration: time.Millisecond}
+	verifier := NewJwtVerifier(remote)
+	token, err := issuer.CreateToken(newTestClaims())
+	require.NoError(t, err)
+	_, err = verifier.ParseToken(token)
+	require.NoError(t, err)
+
+	// 远程服务无响应时，超时后继续使用缓存的密钥
+	hang.Store(true)
+	time.Sleep(5 * time.Millisecond)
+	start := time.Now()
+	_, err = verifier.ParseToken(token)
+	assertion.NoError(err)
+	assertion.Less(time.Since(start), time.Second)
+	assertion.Error(remote.Refresh())
+	_, err = verifier.ParseToken(token)
+	assertion.NoError(err)
+}
+
+func TestJWKAlg(t *testing.T) {
+	jwk, err := NewJWK(newTestRSAKey(t, "rs"))
+	require.NoError(t, err)
+
+	jwk.Alg = "PS256"
+	key, err := jwk.Key()
+	require.NoError(t, err)
+	assert.Equal(t, "PS256", key.Method.Alg())
+
+	// RSA密钥不能指定其他算法
+	for _, alg := range []string{"HS256", "ES256", "EdDSA", "none"} {
+		jwk.Alg = alg
+		_, err = jwk.Key()
+		assert.Equal(t, ErrUnsupportedKey, err, alg)
+	}
+}
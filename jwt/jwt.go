@@ -1,3 +1,8 @@
+// Package jwt 签发和验证JWT，支持HMAC/RSA/ECDSA/EdDSA签名和按kid轮换密钥。
+// 可以通过JWKS发布公钥，也可以用RemoteJWKS验证其他服务签发的token。
+// RevocationStore基于redis吊销token。
+// TokenService签发access/refresh token并轮换refresh token。
+// 设置Encryption后生成签名后再加密的JWE。
 package jwt
 
 /*
@@ -8,13 +13,27 @@ package jwt
 
 import (
 	"errors"
+	"sync"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 // JwtSign 结构体
+// 仅设置SigningKey时，使用HS256签名，token header中不含kid（兼容旧版本）；
+// 使用密钥环(NewJwtSignWithKeys、AddKey)并SetActiveKey后，使用该密钥签名，并在header中写入kid，验证时根据kid选择密钥。
+// 轮换密钥时，先AddKey新密钥并SetActiveKey，旧密钥保留在密钥环中直到用它签发的token全部过期，再RemoveKey。
 type JwtSign struct {
-	SigningKey []byte
+	SigningKey []byte             // HS256的密钥，未设置签名密钥(SetActiveKey)时使用
+	KeySource  KeySource          // 密钥环中找不到kid时，从这里获取验证密钥，例如RemoteJWKS
+	Validation *ValidationOptions // 解析token时对claims的额外校验，为nil时仅校验签名和exp、nbf
+	Revocation RevocationChecker  // 解析token时查询是否已被吊销，例如RevocationStore，为nil时不查询
+	Encryption *EncryptionKey     // 设置时，CreateToken生成签名后再加密的JWE；ParseToken同时接受JWE和未加密的token
+
+	mu          sync.RWMutex    // 保护keys和activeKeyID，密钥轮换可以与签发、解析token并发进行
+	keys        map[string]*Key // 密钥环，key为kid
+	activeKeyID string          // CreateToken时使用的密钥的kid
 }
 
 // 一些常量
@@ -23,6 +42,18 @@ var (
 	TokenNotValidYet = errors.New("token not active yet")
 	TokenMalformed   = errors.New("that's not even a token")
 	TokenInvalid     = errors.New("couldn't handle this token")
+
+	// 以下错误仅在设置了Validation时出现
+	TokenInvalidIssuer        = errors.New("token has invalid issuer")
+	TokenInvalidAudience      = errors.New("token has invalid audience")
+	TokenUsedBeforeIssued     = errors.New("token used before issued")
+	TokenMissingExpiration    = errors.New("token is missing exp claim")
+	TokenMissingIssuedAt      = errors.New("token is missing iat claim")
+	TokenMissingSubject       = errors.New("token is missing sub claim")
+	TokenInvalidSigningMethod = errors.New("token signing method is not allowed")
+
+	// 仅在设置了Revocation时出现
+	TokenRevoked = errors.New("token has been revoked")
 )
 
 // CustomClaims 用于构成payload
@@ -34,84 +65,192 @@ type CustomClaims struct {
 // 新建一个JwtSign实例
 func NewJwtSign(jwtSecret string) *JwtSign {
 	return &JwtSign{
-		[]byte(jwtSecret),
+		SigningKey: []byte(jwtSecret),
 	}
 }
 
-// CreateToken 生成一个token
-func (j *JwtSign) CreateToken(claims CustomClaims) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(j.SigningKey)
+// NewJwtSignWithKeys 使用密钥环新建一个JwtSign实例，activeKeyID为签名所用密钥的kid。
+// 仅用于验证的服务，activeKeyID可以为空，keys中只放公钥。keys中有nil时返回ErrNilKey
+func NewJwtSignWithKeys(activeKeyID string, keys ...*Key) (*JwtSign, error) {
+	j := &JwtSign{}
+	for _, key := range keys {
+		if err := j.AddKey(key); err != nil {
+			return nil, err
+		}
+	}
+	if activeKeyID != "" {
+		if err := j.SetActiveKey(activeKeyID); err != nil {
+			return nil, err
+		}
+	}
+	return j, nil
 }
 
-// ParseToken 解析一个token
-func (j *JwtSign) ParseToken(tokenString string) (*CustomClaims, error) {
-	// 因为我们只使用一个私钥来签署令牌，所以我们也只使用它的公共计数器部分来验证
-	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return j.SigningKey, nil
-	})
-	if err != nil {
-		if errors.Is(err, jwt.ErrTokenMalformed) {
-			return nil, TokenMalformed
-		} else if errors.Is(err, jwt.ErrTokenExpired) {
-			return nil, TokenExpired
-		} else if errors.Is(err, jwt.ErrTokenNotValidYet) {
-			return nil, TokenNotValidYet
-		} else {
-			return nil, TokenInvalid
+// AddKey 向密钥环中添加一个密钥，如kid已存在则替换。key为nil时返回ErrNilKey
+func (j *JwtSign) AddKey(key *Key) error {
+	if key == nil {
+		return ErrNilKey
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.keys == nil {
+		j.keys = make(map[string]*Key)
+	}
+	j.keys[key.ID] = key
+	return nil
+}
+
+// RemoveKey 从密钥环中删除一个密钥，之后用此密钥签发的token将无法通过验证。
+// 不能删除正在用于签名的密钥。
+func (j *JwtSign) RemoveKey(kid string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if kid == j.activeKeyID {
+		return ErrKeyInUse
+	}
+	delete(j.keys, kid)
+	return nil
+}
+
+// SetActiveKey 设置CreateToken时使用的密钥，该密钥必须已在密钥环中且包含私钥
+func (j *JwtSign) SetActiveKey(kid string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	key, ok := j.keys[kid]
+	if !ok {
+		return ErrKeyNotFound
+	}
+	if !key.CanSign() {
+		return ErrKeyCannotSign
+	}
+	j.activeKeyID = kid
+	return nil
+}
+
+// ActiveKeyID 当前用于签名的密钥的kid，未设置时为空，使用SigningKey
+func (j *JwtSign) ActiveKeyID() string {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.activeKeyID
+}
+
+// Key 根据kid获取密钥环中的密钥，不查询KeySource
+func (j *JwtSign) Key(kid string) (*Key, bool) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	key, ok := j.keys[kid]
+	return key, ok
+}
+
+// Keys 密钥环中所有密钥的副本，key为kid，修改返回的map不影响密钥环
+func (j *JwtSign) Keys() map[string]*Key {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	keys := make(map[string]*Key, len(j.keys))
+	for kid, key := range j.keys {
+		keys[kid] = key
+	}
+	return keys
+}
+
+// signingKey 获取用于签名的密钥
+func (j *JwtSign) signingKey() (*Key, error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	if j.activeKeyID == "" {
+		if len(j.SigningKey) == 0 {
+			return nil, ErrNoActiveKey
+		}
+		return NewHMACKey("", j.SigningKey), nil
+	}
+	key, ok := j.keys[j.activeKeyID]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	if !key.CanSign() {
+		return nil, ErrKeyCannotSign
+	}
+	return key, nil
+}
+
+// verificationKey 根据kid获取用于验证的密钥。kid为空时，使用SigningKey(HS256)
+func (j *JwtSign) verificationKey(kid string) (*Key, error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	if kid == "" {
+		if len(j.SigningKey) == 0 {
+			return nil, ErrKeyNotFound
 		}
+		return NewHMACKey("", j.SigningKey), nil
+	}
+	if key, ok := j.keys[kid]; ok {
+		return key, nil
 	}
-	if claims, ok := token.Claims.(*CustomClaims); ok && token.Valid {
-		return claims, nil
-	}
-	return nil, TokenInvalid
-}
-
-// RefreshToken 更新一个token
-// func (j *JwtSign) RefreshToken(tokenString string) (string, error) {
-// 	jwtDuration, _ := time.ParseDuration(conf.JwtDuration)
-// 	jwt.TimeFunc = func() time.Time {
-// 		return time.Unix(0, 0)
-// 	}
-// 	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(token *jwt.Token) (i interface{}, e error) {
-// 		return j.SigningKey, nil
-// 	})
-// 	if err != nil {
-// 		return "", err
-// 	}
-// 	if claims, ok := token.Claims.(*CustomClaims); ok && token.Valid {
-// 		jwt.TimeFunc = time.Now
-// 		claims.StandardClaims.ExpiresAt = time.Now().Add(jwtDuration).Unix()
-// 		return j.CreateToken(*claims)
-// 	}
-// 	return "", TokenInvalid
-// }
+	if j.KeySource != nil {
+		return j.KeySource.Key(kid)
+	}
+	return nil, ErrKeyNotFound
+}
 
-// GetClaimsFromExpiredToken 从一个过期的token中获取claims
-func (j *JwtSign) GetClaimsFromExpiredToken(tokenString string) (*CustomClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return j.SigningKey, nil
-	})
+// keyFunc 供jwt.ParseWithClaims使用，确认签名算法在允许范围内，根据header中的kid选择验证密钥，并确认token的签名算法与密钥一致
+func (j *JwtSign) keyFunc(token *jwt.Token) (interface{}, error) {
+	if !j.Validation.methodAllowed(token.Method.Alg()) {
+		return nil, TokenInvalidSigningMethod
+	}
+	kid, _ := token.Header["kid"].(string)
+	key, err := j.verificationKey(kid)
+	if err != nil {
+		return nil, err
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, ErrMethodMismatch
+	}
+	return key.PublicKey, nil
+}
 
+// CreateToken 生成一个token
+// 需要自定义claims类型时，使用NewSigner
+func (j *JwtSign) CreateToken(claims CustomClaims) (string, error) {
+	return NewSigner[CustomClaims](j).CreateToken(claims)
+}
+
+// createToken 使用当前签名密钥签发token，kid写入header，设置了Encryption时再加密。
+// claims中未设置jti和iat时自动生成，用于吊销token
+func (j *JwtSign) createToken(claims jwt.Claims) (string, error) {
+	key, err := j.signingKey()
 	if err != nil {
-		if errors.Is(err, jwt.ErrTokenExpired) {
-			if claims, ok := token.Claims.(*CustomClaims); ok {
-				return claims, nil
+		return "", err
+	}
+	if rc := registeredClaims(claims); rc != nil {
+		if rc.ID == "" {
+			// UUIDv7中带有毫秒精度的签发时间，按用户吊销时用于区分与吊销时间同一秒内签发的token，见preciseIssuedAt
+			id, err := uuid.NewV7()
+			if err != nil {
+				return "", err
 			}
-			return nil, TokenExpired
+			rc.ID = id.String()
 		}
-		if errors.Is(err, jwt.ErrTokenMalformed) {
-			return nil, TokenMalformed
+		if rc.IssuedAt == nil {
+			rc.IssuedAt = jwt.NewNumericDate(time.Now())
 		}
-		if errors.Is(err, jwt.ErrTokenNotValidYet) {
-			return nil, TokenNotValidYet
-		}
-		return nil, TokenInvalid
 	}
-
-	if claims, ok := token.Claims.(*CustomClaims); ok && token.Valid {
-		return claims, nil
+	token := jwt.NewWithClaims(key.Method, claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	signed, err := token.SignedString(key.PrivateKey)
+	if err != nil {
+		return "", err
 	}
+	return j.encrypt(signed)
+}
 
-	return nil, TokenInvalid
+// ParseToken 解析一个token
+func (j *JwtSign) ParseToken(tokenString string) (*CustomClaims, error) {
+	return NewSigner[CustomClaims](j).ParseToken(tokenString)
+}
+
+// GetClaimsFromExpiredToken 从一个过期的token中获取claims
+func (j *JwtSign) GetClaimsFromExpiredToken(tokenString string) (*CustomClaims, error) {
+	return NewSigner[CustomClaims](j).GetClaimsFromExpiredToken(tokenString)
 }
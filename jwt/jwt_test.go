@@ -1,6 +1,11 @@
 package jwt
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
 	"fmt"
 	"testing"
 	"time"
@@ -45,3 +50,108 @@ func TestToken(t *testing.T) {
 func TestParseToken(t *testing.T) {
 
 }
+
+func newTestClaims() CustomClaims {
+	return CustomClaims{
+		UserID: uuid.NewString(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   random.RandomString(5),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute * 5)),
+		},
+	}
+}
+
+func TestAsymmetricKeys(t *testing.T) {
+	assertion := assert.New(t)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assertion.Nil(err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assertion.Nil(err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assertion.Nil(err)
+
+	esKey, err := NewECDSAKey("es", ecKey)
+	assertion.Nil(err)
+	keys := []*Key{NewRSAKey("rs", rsaKey), esKey, NewEdDSAKey("ed", edKey)}
+	algs := []string{"RS256", "ES256", "EdDSA"}
+
+	for i, key := range keys {
+		jwtSign, err := NewJwtSignWithKeys(key.ID, key)
+		assertion.Nil(err)
+		claim := newTestClaims()
+		token, err := jwtSign.CreateToken(claim)
+		assertion.Nil(err)
+
+		parsed, _, err := jwt.NewParser().ParseUnverified(token, &CustomClaims{})
+		assertion.Nil(err)
+		assertion.Equal(algs[i], parsed.Method.Alg())
+		assertion.Equal(key.ID, parsed.Header["kid"])
+
+		// 仅持有公钥的一方也可以验证
+		verifier, err := NewJwtSignWithKeys("", &Key{ID: key.ID, Method: key.Method, PublicKey: key.PublicKey})
+		assertion.Nil(err)
+		parsedClaim, err := verifier.ParseToken(token)
+		assertion.Nil(err)
+		assertion.Equal(claim.UserID, parsedClaim.UserID)
+
+		// 仅持有公钥时不能签名
+		_, err = verifier.CreateToken(claim)
+		assertion.Equal(ErrNoActiveKey, err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	assertion := assert.New(t)
+	claim := newTestClaims()
+
+	jwtSign, err := NewJwtSignWithKeys("k1", NewHMACKey("k1", []byte(random.RandomString(12))))
+	assertion.Nil(err)
+	oldToken, err := jwtSign.CreateToken(claim)
+	assertion.Nil(err)
+
+	// 轮换到新密钥后，新旧token都能通过验证
+	assertion.Nil(jwtSign.AddKey(NewHMACKey("k2", []byte(random.RandomString(12)))))
+	assertion.Nil(jwtSign.SetActiveKey("k2"))
+	assertion.Equal("k2", jwtSign.ActiveKeyID())
+	assertion.Len(jwtSign.Keys(), 2)
+	newToken, err := jwtSign.CreateToken(claim)
+	assertion.Nil(err)
+	_, err = jwtSign.ParseToken(oldToken)
+	assertion.Nil(err)
+	_, err = jwtSign.ParseToken(newToken)
+	assertion.Nil(err)
+
+	// 删除旧密钥后，旧token失效
+	assertion.Equal(ErrKeyInUse, jwtSign.RemoveKey("k2"))
+	assertion.Nil(jwtSign.RemoveKey("k1"))
+	_, err = jwtSign.ParseToken(oldToken)
+	assertion.Equal(TokenInvalid, err)
+	_, err = jwtSign.ParseToken(newToken)
+	assertion.Nil(err)
+	_, ok := jwtSign.Key("k1")
+	assertion.False(ok)
+
+	// nil密钥返回错误而不是panic
+	_, err = NewJwtSignWithKeys("", nil)
+	assertion.Equal(ErrNilKey, err)
+	assertion.Equal(ErrNilKey, jwtSign.AddKey(nil))
+}
+
+func TestSigningMethodMismatch(t *testing.T) {
+	assertion := assert.New(t)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assertion.Nil(err)
+	key := NewRSAKey("rs", rsaKey)
+	jwtSign, err := NewJwtSignWithKeys("rs", key)
+	assertion.Nil(err)
+
+	// 用公钥作为HMAC secret伪造的token不能通过验证
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, newTestClaims())
+	forged.Header["kid"] = "rs"
+	tokenString, err := forged.SignedString([]byte("rs"))
+	assertion.Nil(err)
+	_, err = jwtSign.ParseToken(tokenString)
+	assertion.Equal(TokenInvalid, err)
+}
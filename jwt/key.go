@@ -0,0 +1,152 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"errors"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 密钥相关的错误
+var (
+	ErrKeyNotFound    = errors.New("signing key not found")
+	ErrNoActiveKey    = errors.New("no active signing key")
+	ErrKeyCannotSign  = errors.New("key has no private part and cannot sign")
+	ErrKeyInUse       = errors.New("cannot remove the active signing key")
+	ErrUnsupportedKey = errors.New("unsupported key type")
+	ErrMethodMismatch = errors.New("signing method does not match the key")
+	ErrNilKey         = errors.New("key is nil")
+)
+
+// Key 签名/验证密钥，通过kid(Key ID)在密钥环中区分
+type Key struct {
+	ID         string            // kid，CreateToken时写入token header
+	Method     jwt.SigningMethod // 签名算法，如 jwt.SigningMethodRS256
+	PrivateKey interface{}       // 签名用：HMAC为[]byte，RSA为*rsa.PrivateKey，ECDSA为*ecdsa.PrivateKey，EdDSA为ed25519.PrivateKey。仅用于验证时为nil
+	PublicKey  interface{}       // 验证用：HMAC为[]byte，RSA为*rsa.PublicKey，ECDSA为*ecdsa.PublicKey，EdDSA为ed25519.PublicKey
+}
+
+// CanSign 该密钥是否可以用于签名
+func (k *Key) CanSign() bool {
+	return k.PrivateKey != nil
+}
+
+// NewHMACKey 新建一个HS256密钥，签名与验证使用同一个secret
+func NewHMACKey(kid string, secret []byte) *Key {
+	return &Key{
+		ID:         kid,
+		Method:     jwt.SigningMethodHS256,
+		PrivateKey: secret,
+		PublicKey:  secret,
+	}
+}
+
+// NewRSAKey 新建一个RS256密钥对
+func NewRSAKey(kid string, privateKey *rsa.PrivateKey) *Key {
+	return &Key{
+		ID:         kid,
+		Method:     jwt.SigningMethodRS256,
+		PrivateKey: privateKey,
+		PublicKey:  &privateKey.PublicKey,
+	}
+}
+
+// NewRSAPublicKey 新建一个仅用于验证的RS256公钥
+func NewRSAPublicKey(kid string, publicKey *rsa.PublicKey) *Key {
+	return &Key{
+		ID:        kid,
+		Method:    jwt.SigningMethodRS256,
+		PublicKey: publicKey,
+	}
+}
+
+// NewECDSAKey 新建一个ECDSA密钥对，根据曲线选择ES256(P-256)、ES384(P-384)或ES512(P-521)
+func NewECDSAKey(kid string, privateKey *ecdsa.PrivateKey) (*Key, error) {
+	method, err := ecdsaMethod(privateKey.Curve)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{
+		ID:         kid,
+		Method:     method,
+		PrivateKey: privateKey,
+		PublicKey:  &privateKey.PublicKey,
+	}, nil
+}
+
+// NewECDSAPublicKey 新建一个仅用于验证的ECDSA公钥
+func NewECDSAPublicKey(kid string, publicKey *ecdsa.PublicKey) (*Key, error) {
+	method, err := ecdsaMethod(publicKey.Curve)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{
+		ID:        kid,
+		Method:    method,
+		PublicKey: publicKey,
+	}, nil
+}
+
+// NewEdDSAKey 新建一个EdDSA(Ed25519)密钥对
+func NewEdDSAKey(kid string, privateKey ed25519.PrivateKey) *Key {
+	return &Key{
+		ID:         kid,
+		Method:     jwt.SigningMethodEdDSA,
+		PrivateKey: privateKey,
+		PublicKey:  privateKey.Public(),
+	}
+}
+
+// NewEdDSAPublicKey 新建一个仅用于验证的EdDSA(Ed25519)公钥
+func NewEdDSAPublicKey(kid string, publicKey ed25519.PublicKey) *Key {
+	return &Key{
+		ID:        kid,
+		Method:    jwt.SigningMethodEdDSA,
+		PublicKey: publicKey,
+	}
+}
+
+// NewKeyFromPEM 从PEM编码的私钥或公钥新建一个密钥，支持RSA、ECDSA和Ed25519。
+// 如果是私钥，则返回的密钥可用于签名；如果是公钥，则仅可用于验证。
+func NewKeyFromPEM(kid string, pemBytes []byte) (*Key, error) {
+	if privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(pemBytes); err == nil {
+		return NewRSAKey(kid, privateKey), nil
+	}
+	if privateKey, err := jwt.ParseECPrivateKeyFromPEM(pemBytes); err == nil {
+		return NewECDSAKey(kid, privateKey)
+	}
+	if privateKey, err := jwt.ParseEdPrivateKeyFromPEM(pemBytes); err == nil {
+		if edKey, ok := privateKey.(ed25519.PrivateKey); ok {
+			return NewEdDSAKey(kid, edKey), nil
+		}
+	}
+	if publicKey, err := jwt.ParseRSAPublicKeyFromPEM(pemBytes); err == nil {
+		return NewRSAPublicKey(kid, publicKey), nil
+	}
+	if publicKey, err := jwt.ParseECPublicKeyFromPEM(pemBytes); err == nil {
+		return NewECDSAPublicKey(kid, publicKey)
+	}
+	if publicKey, err := jwt.ParseEdPublicKeyFromPEM(pemBytes); err == nil {
+		if edKey, ok := publicKey.(ed25519.PublicKey); ok {
+			return NewEdDSAPublicKey(kid, edKey), nil
+		}
+	}
+	return nil, ErrUnsupportedKey
+}
+
+// ecdsaMethod 根据椭圆曲线得到对应的签名算法
+func ecdsaMethod(curve elliptic.Curve) (jwt.SigningMethod, error) {
+	switch curve {
+	case elliptic.P256():
+		return jwt.SigningMethodES256, nil
+	case elliptic.P384():
+		return jwt.SigningMethodES384, nil
+	case elliptic.P521():
+		return jwt.SigningMethodES512, nil
+	default:
+		return nil, ErrUnsupportedKey
+	}
+}
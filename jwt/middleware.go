@@ -0,0 +1,128 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// TokenMissing 请求中没有找到token
+var TokenMissing = errors.New("no token found in request")
+
+// tokenErrors 解析token时可能返回的错误，中间件将其转为401，其他错误(如redis不可用)转为500
+var tokenErrors = []error{
+	TokenMissing, TokenExpired, TokenNotValidYet, TokenMalformed, TokenInvalid,
+	TokenInvalidIssuer, TokenInvalidAudience, TokenUsedBeforeIssued,
+	TokenMissingExpiration, TokenMissingIssuedAt, TokenMissingSubject,
+	TokenInvalidSigningMethod, TokenRevoked,
+}
+
+// claimsContextKey 在request context中保存claims的key
+type claimsContextKey struct{}
+
+// MiddlewareOptions 认证中间件的配置
+// token依次从 Authorization: Bearer xxx、CookieName指定的cookie、QueryParam指定的query参数中读取
+type MiddlewareOptions struct {
+	CookieName   string                                                  // 为空时不从cookie读取
+	QueryParam   string                                                  // 为空时不从query读取，如 "access_token"
+	Realm        string                                                  // WWW-Authenticate中的realm，为空时不输出
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, err error) // 自定义错误响应，为nil时使用DefaultErrorHandler
+}
+
+// Middleware 返回net/http的认证中间件，验证通过后将claims放入request context，用ClaimsFromContext读取
+func Middleware[T any, PT ClaimsPointer[T]](signer *Signer[T, PT], options MiddlewareOptions) func(http.Handler) http.Handler {
+	errorHandler := options.ErrorHandler
+	if errorHandler == nil {
+		errorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+			DefaultErrorHandler(w, r, err, options.Realm)
+		}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokenString := options.extractToken(r)
+			if tokenString == "" {
+				errorHandler(w, r, TokenMissing)
+				return
+			}
+			claims, err := signer.ParseToken(tokenString)
+			if err != nil {
+				errorHandler(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(ContextWithClaims(r.Context(), claims)))
+		})
+	}
+}
+
+// Middleware 使用CustomClaims的认证中间件，用CustomClaimsFromContext读取claims
+func (j *JwtSign) Middleware(options MiddlewareOptions) func(http.Handler) http.Handler {
+	return Middleware(NewSigner[CustomClaims](j), options)
+}
+
+// ContextWithClaims 将claims放入context，测试handler时也可以直接使用
+func ContextWithClaims[T any](ctx context.Context, claims *T) context.Context {
+	return context.WithValue(ctx, claimsContextKey{}, claims)
+}
+
+// ClaimsFromContext 读取中间件放入context的claims，T需与中间件使用的claims类型一致
+func ClaimsFromContext[T any](ctx context.Context) (*T, bool) {
+	claims, ok := ctx.Value(claimsContextKey{}).(*T)
+	return claims, ok
+}
+
+// CustomClaimsFromContext 读取JwtSign.Middleware放入context的claims
+func CustomClaimsFromContext(ctx context.Context) (*CustomClaims, bool) {
+	return ClaimsFromContext[CustomClaims](ctx)
+}
+
+// DefaultErrorHandler 默认的错误响应：token相关的错误返回401及RFC 6750格式的WWW-Authenticate，其他错误返回500
+func DefaultErrorHandler(w http.ResponseWriter, r *http.Request, err error, realm string) {
+	if !IsTokenError(err) {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	params := make([]string, 0, 3)
+	if realm != "" {
+		params = append(params, fmt.Sprintf("realm=%q", realm))
+	}
+	// 没有携带token时，不返回error (RFC 6750 3.1)
+	if !errors.Is(err, TokenMissing) {
+		params = append(params, `error="invalid_token"`, fmt.Sprintf("error_description=%q", err.Error()))
+	}
+	challenge := "Bearer"
+	if len(params) > 0 {
+		challenge += " " + strings.Join(params, ", ")
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	http.Error(w, err.Error(), http.StatusUnauthorized)
+}
+
+// IsTokenError 是否是token本身的问题(缺失、过期、无效等)，而非服务端错误
+func IsTokenError(err error) bool {
+	for _, tokenErr := range tokenErrors {
+		if errors.Is(err, tokenErr) {
+			return true
+		}
+	}
+	return false
+}
+
+// extractToken 从请求中读取token
+func (options *MiddlewareOptions) extractToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if scheme, token, ok := strings.Cut(auth, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+	if options.CookieName != "" {
+		if cookie, err := r.Cookie(options.CookieName); err == nil && cookie.Value != "" {
+			return cookie.Value
+		}
+	}
+	if options.QueryParam != "" {
+		return r.URL.Query().Get(options.QueryParam)
+	}
+	return ""
+}
@@ -0,0 +1,104 @@
+package jwt
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/adamesong/go-util/random"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	jwtSign := NewJwtSign(random.RandomString(12))
+	claims := newTestClaims()
+	token, err := jwtSign.CreateToken(claims)
+	require.NoError(t, err)
+	expiredClaims := newTestClaims()
+	expiredClaims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	expiredToken, err := jwtSign.CreateToken(expiredClaims)
+	require.NoError(t, err)
+
+	handler := jwtSign.Middleware(MiddlewareOptions{
+		CookieName: "access_token",
+		QueryParam: "access_token",
+		Realm:      "api",
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parsed, ok := CustomClaimsFromContext(r.Context())
+		require.True(t, ok)
+		_, _ = w.Write([]byte(parsed.UserID))
+	}))
+
+	cases := []struct {
+		name      string
+		request   func() *http.Request
+		code      int
+		challenge string
+	}{
+		{"header", func() *http.Request {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			return req
+		}, http.StatusOK, ""},
+		{"cookie", func() *http.Request {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(&http.Cookie{Name: "access_token", Value: token})
+			return req
+		}, http.StatusOK, ""},
+		{"query", func() *http.Request {
+			return httptest.NewRequest(http.MethodGet, "/?access_token="+token, nil)
+		}, http.StatusOK, ""},
+		{"missing", func() *http.Request {
+			return httptest.NewRequest(http.MethodGet, "/", nil)
+		}, http.StatusUnauthorized, `Bearer realm="api"`},
+		{"expired", func() *http.Request {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+expiredToken)
+			return req
+		}, http.StatusUnauthorized, `Bearer realm="api", error="invalid_token", error_description="token is expired"`},
+		{"malformed", func() *http.Request {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "bearer not-a-token")
+			return req
+		}, http.StatusUnauthorized, `Bearer realm="api", error="invalid_token", error_description="that's not even a token"`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, c.request())
+			assert.Equal(t, c.code, recorder.Code)
+			assert.Equal(t, c.challenge, recorder.Header().Get("WWW-Authenticate"))
+			if c.code == http.StatusOK {
+				assert.Equal(t, claims.UserID, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestMiddlewareCustomClaims(t *testing.T) {
+	signer := NewSigner[tenantClaims](NewJwtSign(random.RandomString(12)))
+	token, err := signer.CreateToken(tenantClaims{TenantID: "tenant-1"})
+	require.NoError(t, err)
+
+	handler := Middleware(signer, MiddlewareOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := ClaimsFromContext[tenantClaims](r.Context())
+		require.True(t, ok)
+		_, _ = w.Write([]byte(claims.TenantID))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "tenant-1", recorder.Body.String())
+
+	// 未配置query参数时，不从query读取
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/?access_token="+token, nil))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.Equal(t, "Bearer", recorder.Header().Get("WWW-Authenticate"))
+}
@@ -0,0 +1,178 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/adamesong/go-util/redis"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const (
+	REVOKED_JTI_PREFIX  = "jwt_revoked_jti:"  // 被吊销的单个token，value为1
+	REVOKED_USER_PREFIX = "jwt_revoked_user:" // 按用户吊销，value为unix时间戳(毫秒)，早于此时间签发的token都被吊销
+)
+
+// revokeUserScript 只在新的吊销时间晚于已有的时才写入，并发调用时吊销时间不会倒退。ARGV[2]为过期时间(毫秒)，0为不过期
+var revokeUserScript = goredis.NewScript(`
+local existing = tonumber(redis.call("GET", KEYS[1]))
+if existing and existing >= tonumber(ARGV[1]) then
+	return 0
+end
+if tonumber(ARGV[2]) > 0 then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+else
+	redis.call("SET", KEYS[1], ARGV[1])
+end
+return 1
+`)
+
+// RevocationChecker ParseToken时查询token是否已被吊销
+type RevocationChecker interface {
+	IsRevoked(jti, userID string, issuedAt time.Time) (bool, error)
+}
+
+// RevocationStore 基于redis的token吊销列表，实现RevocationChecker
+type RevocationStore struct {
+	Redis   *redis.RedisClient
+	UserTTL time.Duration // 按用户吊销的记录保留时长，应不短于access token的最长有效期
+}
+
+// NewRevocationStore 新建一个RevocationStore，maxTokenLifetime为access token的最长有效期
+func NewRevocationStore(client *redis.RedisClient, maxTokenLifetime time.Duration) *RevocationStore {
+	return &RevocationStore{
+		Redis:   client,
+		UserTTL: maxTokenLifetime,
+	}
+}
+
+// Revoke 吊销一个token，记录保留到该token过期为止。token已过期时无需记录
+func (s *RevocationStore) Revoke(jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	return s.Redis.Set(REVOKED_JTI_PREFIX+jti, 1, ttl)
+}
+
+// RevokeUserBefore 吊销某用户在before之前签发的所有token，例如修改密码或"退出所有设备"时。
+// 吊销时间精确到毫秒，之后签发的token不受影响，修改密码后可以立即重新登录。iat只精确到秒，
+// 由CreateToken生成jti的token按jti中的毫秒时间判断；其他token与before同一秒内签发的都视为已吊销。
+// 如已有更晚的吊销时间，则保留更晚的。
+func (s *RevocationStore) RevokeUserBefore(userID string, before time.Time) error {
+	return revokeUserScript.Run(context.Background(), s.Redis.Client, []string{REVOKED_USER_PREFIX + userID},
+		before.UnixMilli(), s.UserTTL.Milliseconds()).Err()
+}
+
+// IsRevoked 查询token是否已被吊销：jti被单独吊销，或签发时间早于该用户的吊销时间。
+// 用户有吊销记录而token不含iat时，视为已吊销。
+func (s *RevocationStore) IsRevoked(jti, userID string, issuedAt time.Time) (bool, error) {
+	if jti != "" {
+		n, err := s.Redis.Exists(REVOKED_JTI_PREFIX + jti)
+		if err != nil {
+			return false, err
+		}
+		if n > 0 {
+			return true, nil
+		}
+	}
+	if userID == "" {
+		return false, nil
+	}
+	value, err := s.Redis.Get(REVOKED_USER_PREFIX + userID)
+	if err != nil {
+		if errors.Is(err, goredis.Nil) {
+			return false, nil
+		}
+		return false, err
+	}
+	before, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return false, err
+	}
+	return issuedAt.IsZero() || issuedAt.UnixMilli() < before, nil
+}
+
+// userIDClaims claims中带有用户ID时实现此接口，如CustomClaims；未实现时以sub作为用户ID
+type userIDClaims interface {
+	GetUserID() string
+}
+
+// GetUserID 获取用户ID，用于按用户吊销token
+func (c CustomClaims) GetUserID() string {
+	return c.UserID
+}
+
+// claimsUserID 获取claims中的用户ID
+func claimsUserID(claims jwt.Claims) string {
+	if c, ok := claims.(userIDClaims); ok && c.GetUserID() != "" {
+		return c.GetUserID()
+	}
+	subject, _ := claims.GetSubject()
+	return subject
+}
+
+// registeredClaims 获取claims中内嵌的jwt.RegisteredClaims，用于读写jti等字段。未内嵌时返回nil
+func registeredClaims(claims jwt.Claims) *jwt.RegisteredClaims {
+	if rc, ok := claims.(*jwt.RegisteredClaims); ok {
+		return rc
+	}
+	v := reflect.ValueOf(claims)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	field := v.Elem().FieldByName("RegisteredClaims")
+	if !field.IsValid() {
+		return nil
+	}
+	switch rc := field.Addr().Interface().(type) {
+	case *jwt.RegisteredClaims:
+		return rc
+	case **jwt.RegisteredClaims:
+		return *rc
+	default:
+		return nil
+	}
+}
+
+// checkRevoked 如设置了Revocation，查询token是否已被吊销
+func (j *JwtSign) checkRevoked(claims jwt.Claims) error {
+	if j.Revocation == nil {
+		return nil
+	}
+	var jti string
+	if rc := registeredClaims(claims); rc != nil {
+		jti = rc.ID
+	}
+	var issuedAt time.Time
+	if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+		issuedAt = preciseIssuedAt(jti, iat.Time)
+	}
+	revoked, err := j.Revocation.IsRevoked(jti, claimsUserID(claims), issuedAt)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return TokenRevoked
+	}
+	return nil
+}
+
+// preciseIssuedAt iat只精确到秒，jti是CreateToken生成的UUIDv7时，用其中毫秒精度的时间作为签发时间。
+// 只在与iat同一秒时才采用，jti不会使token的签发时间超出iat所在的那一秒
+func preciseIssuedAt(jti string, issuedAt time.Time) time.Time {
+	id, err := uuid.Parse(jti)
+	if err != nil || id.Version() != 7 {
+		return issuedAt
+	}
+	t := time.Unix(id.Time().UnixTime())
+	if t.Before(issuedAt) || !t.Truncate(time.Second).Equal(issuedAt.Truncate(time.Second)) {
+		return issuedAt
+	}
+	return t
+}
@@ -0,0 +1,153 @@
+package jwt
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adamesong/go-util/random"
+	"github.com/adamesong/go-util/redis"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateTokenStampsJTI(t *testing.T) {
+	jwtSign := NewJwtSign(random.RandomString(12))
+	signer := NewSigner[tenantClaims](jwtSign)
+
+	claims := tenantClaims{TenantID: "tenant-1"}
+	token, err := signer.CreateToken(claims)
+	require.NoError(t, err)
+	parsed, err := signer.ParseToken(token)
+	require.NoError(t, err)
+	assert.NotEmpty(t, parsed.ID)
+	assert.NotNil(t, parsed.IssuedAt)
+	// 调用方传入的claims不会被修改
+	assert.Empty(t, claims.ID)
+
+	// 已设置的jti保持不变
+	claims.ID = "my-jti"
+	token, err = signer.CreateToken(claims)
+	require.NoError(t, err)
+	parsed, err = signer.ParseToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, "my-jti", parsed.ID)
+}
+
+func TestRevocationStore(t *testing.T) {
+	redisClient, err := redis.NewRedisClient("localhost:6379", "", 0)
+	require.NoError(t, err, "Failed to initialize redis client for test")
+	defer redisClient.Close()
+
+	store := NewRevocationStore(redisClient, time.Hour)
+	jwtSign := NewJwtSign(random.RandomString(12))
+	jwtSign.Revocation = store
+
+	userID := "revocation-test-" + random.RandomString(8)
+	claims := newTestClaims()
+	claims.UserID = userID
+	claims.IssuedAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	defer func() {
+		_, _ = redisClient.Delete(REVOKED_USER_PREFIX + userID)
+	}()
+
+	first, err := jwtSign.CreateToken(claims)
+	require.NoError(t, err)
+	second, err := jwtSign.CreateToken(claims)
+	require.NoError(t, err)
+
+	// 吊销单个token
+	firstClaims, err := jwtSign.ParseToken(first)
+	require.NoError(t, err)
+	require.NoError(t, store.Revoke(firstClaims.ID, firstClaims.ExpiresAt.Time))
+	defer func() {
+		_, _ = redisClient.Delete(REVOKED_JTI_PREFIX + firstClaims.ID)
+	}()
+	ttl, err := redisClient.TTL(REVOKED_JTI_PREFIX + firstClaims.ID)
+	require.NoError(t, err)
+	assert.True(t, ttl > 0 && ttl <= 5*time.Minute, "TTL should equal the remaining lifetime")
+
+	_, err = jwtSign.ParseToken(first)
+	assert.Equal(t, TokenRevoked, err)
+	_, err = jwtSign.ParseToken(second)
+	assert.NoError(t, err)
+
+	// 吊销该用户之前签发的所有token，之后签发的不受影响
+	require.NoError(t, store.RevokeUserBefore(userID, time.Now()))
+	_, err = jwtSign.ParseToken(second)
+	assert.Equal(t, TokenRevoked, err)
+
+	claims.IssuedAt = jwt.NewNumericDate(time.Now().Add(time.Second))
+	third, err := jwtSign.CreateToken(claims)
+	require.NoError(t, err)
+	_, err = jwtSign.ParseToken(third)
+	assert.NoError(t, err)
+
+	// 吊销后立即签发的token，即使与吊销时间在同一秒内，也不受影响
+	time.Sleep(2 * time.Millisecond)
+	claims.IssuedAt = nil
+	claims.ID = ""
+	fourth, err := jwtSign.CreateToken(claims)
+	require.NoError(t, err)
+	_, err = jwtSign.ParseToken(fourth)
+	assert.NoError(t, err)
+
+	// 更早的吊销时间不会覆盖更晚的
+	require.NoError(t, store.RevokeUserBefore(userID, time.Now().Add(-time.Hour)))
+	_, err = jwtSign.ParseToken(second)
+	assert.Equal(t, TokenRevoked, err)
+}
+
+func TestRevokeUserBeforePrecision(t *testing.T) {
+	redisClient, err := redis.NewRedisClient("localhost:6379", "", 0)
+	require.NoError(t, err, "Failed to initialize redis client for test")
+	defer redisClient.Close()
+
+	store := NewRevocationStore(redisClient, time.Hour)
+	userID := "revocation-test-" + random.RandomString(8)
+	defer func() {
+		_, _ = redisClient.Delete(REVOKED_USER_PREFIX + userID)
+	}()
+
+	// 并发吊销时保留最晚的吊销时间
+	cutoff := time.Now().Truncate(time.Second).Add(500 * time.Millisecond)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, store.RevokeUserBefore(userID, cutoff.Add(-time.Duration(i)*time.Millisecond)))
+		}(i)
+	}
+	wg.Wait()
+
+	// 与吊销时间同一秒、但更早签发的token也被吊销
+	for issuedAt, revoked := range map[time.Time]bool{
+		cutoff.Truncate(time.Second):                  true,
+		cutoff.Add(-time.Millisecond):                 true,
+		cutoff:                                        false,
+		cutoff.Add(time.Millisecond):                  false,
+		cutoff.Add(-time.Hour):                        true,
+		cutoff.Add(time.Second).Truncate(time.Second): false,
+	} {
+		result, err := store.IsRevoked("", userID, issuedAt)
+		require.NoError(t, err)
+		assert.Equal(t, revoked, result, issuedAt.Format(time.RFC3339Nano))
+	}
+}
+
+func TestPreciseIssuedAt(t *testing.T) {
+	id, err := uuid.NewV7()
+	require.NoError(t, err)
+	issuedAt := time.Unix(id.Time().UnixTime())
+	iat := issuedAt.Truncate(time.Second)
+
+	// UUIDv7的jti补足iat的毫秒，其他jti或不在iat同一秒内时沿用iat
+	assert.Equal(t, issuedAt, preciseIssuedAt(id.String(), iat))
+	assert.Equal(t, iat, preciseIssuedAt("my-jti", iat))
+	assert.Equal(t, iat, preciseIssuedAt(uuid.NewString(), iat))
+	assert.Equal(t, iat.Add(-time.Second), preciseIssuedAt(id.String(), iat.Add(-time.Second)))
+	assert.Equal(t, iat.Add(time.Second), preciseIssuedAt(id.String(), iat.Add(time.Second)))
+}
@@ -0,0 +1,96 @@
+package jwt
+
+import (
+	"errors"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ClaimsPointer 约束自定义claims类型：T为claims结构体(一般内嵌jwt.RegisteredClaims)，*T需实现jwt.Claims
+type ClaimsPointer[T any] interface {
+	*T
+	jwt.Claims
+}
+
+// Signer 使用自定义claims类型签发和解析token，密钥与JwtSign共用。
+// 例如：
+//
+//	type MyClaims struct {
+//		TenantID string   `json:"tenant_id"`
+//		Roles    []string `json:"roles"`
+//		jwt.RegisteredClaims
+//	}
+//	signer := NewSigner[MyClaims](jwtSign)
+//	claims, err := signer.ParseToken(tokenString)
+type Signer[T any, PT ClaimsPointer[T]] struct {
+	JwtSign *JwtSign
+}
+
+// NewSigner 基于一个JwtSign新建Signer，第二个类型参数可以省略
+func NewSigner[T any, PT ClaimsPointer[T]](j *JwtSign) *Signer[T, PT] {
+	return &Signer[T, PT]{JwtSign: j}
+}
+
+// CreateToken 生成一个token
+func (s *Signer[T, PT]) CreateToken(claims T) (string, error) {
+	return s.JwtSign.createToken(PT(&claims))
+}
+
+// ParseToken 解析一个token，错误与JwtSign.ParseToken一致
+func (s *Signer[T, PT]) ParseToken(tokenString string) (*T, error) {
+	tokenString, err := s.JwtSign.decrypt(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	var claims T
+	validation := s.JwtSign.Validation
+	token, err := jwt.ParseWithClaims(tokenString, PT(&claims), s.JwtSign.keyFunc, validation.parserOptions()...)
+	if err != nil {
+		return nil, convertError(err)
+	}
+	if !token.Valid {
+		return nil, TokenInvalid
+	}
+	if err := validation.validate(PT(&claims)); err != nil {
+		return nil, err
+	}
+	if err := s.JwtSign.checkRevoked(PT(&claims)); err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
+
+// GetClaimsFromExpiredToken 从一个过期的token中获取claims，签名、Validation中的其他校验及吊销检查仍然需要通过
+func (s *Signer[T, PT]) GetClaimsFromExpiredToken(tokenString string) (*T, error) {
+	tokenString, err := s.JwtSign.decrypt(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	var claims T
+	validation := s.JwtSign.Validation
+	token, err := jwt.ParseWithClaims(tokenString, PT(&claims), s.JwtSign.keyFunc, validation.parserOptions()...)
+	if err != nil {
+		// 仅过期时才返回claims；jwt库会同时返回其他时间相关的错误，需一并排除
+		if errors.Is(err, jwt.ErrTokenExpired) &&
+			!errors.Is(err, jwt.ErrTokenNotValidYet) && !errors.Is(err, jwt.ErrTokenUsedBeforeIssued) {
+			if err := validation.validate(PT(&claims)); err != nil {
+				return nil, err
+			}
+			if err := s.JwtSign.checkRevoked(PT(&claims)); err != nil {
+				return nil, err
+			}
+			return &claims, nil
+		}
+		return nil, convertError(err)
+	}
+	if !token.Valid {
+		return nil, TokenInvalid
+	}
+	if err := validation.validate(PT(&claims)); err != nil {
+		return nil, err
+	}
+	if err := s.JwtSign.checkRevoked(PT(&claims)); err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
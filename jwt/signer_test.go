@@ -0,0 +1,82 @@
+package jwt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adamesong/go-util/random"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type tenantClaims struct {
+	TenantID string   `json:"tenant_id"`
+	Roles    []string `json:"roles"`
+	Scopes   []string `json:"scopes"`
+	jwt.RegisteredClaims
+}
+
+func TestSignerCustomClaims(t *testing.T) {
+	assertion := assert.New(t)
+	signer := NewSigner[tenantClaims](NewJwtSign(random.RandomString(12)))
+
+	claims := tenantClaims{
+		TenantID: "tenant-1",
+		Roles:    []string{"admin", "editor"},
+		Scopes:   []string{"read", "write"},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "user-1",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}
+	token, err := signer.CreateToken(claims)
+	require.NoError(t, err)
+
+	parsed, err := signer.ParseToken(token)
+	require.NoError(t, err)
+	assertion.Equal(claims.TenantID, parsed.TenantID)
+	assertion.Equal(claims.Roles, parsed.Roles)
+	assertion.Equal(claims.Scopes, parsed.Scopes)
+	assertion.Equal(claims.Subject, parsed.Subject)
+
+	_, err = signer.ParseToken("not-a-token")
+	assertion.Equal(TokenMalformed, err)
+
+	other := NewSigner[tenantClaims](NewJwtSign(random.RandomString(12)))
+	_, err = other.ParseToken(token)
+	assertion.Equal(TokenInvalid, err)
+}
+
+func TestSignerExpiredToken(t *testing.T) {
+	assertion := assert.New(t)
+	signer := NewSigner[tenantClaims](NewJwtSign(random.RandomString(12)))
+
+	claims := tenantClaims{
+		TenantID: "tenant-1",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+		},
+	}
+	token, err := signer.CreateToken(claims)
+	require.NoError(t, err)
+
+	_, err = signer.ParseToken(token)
+	assertion.Equal(TokenExpired, err)
+
+	expired, err := signer.GetClaimsFromExpiredToken(token)
+	require.NoError(t, err)
+	assertion.Equal(claims.TenantID, expired.TenantID)
+
+	notYet := tenantClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			NotBefore: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}
+	token, err = signer.CreateToken(notYet)
+	require.NoError(t, err)
+	_, err = signer.ParseToken(token)
+	assertion.Equal(TokenNotValidYet, err)
+	_, err = signer.GetClaimsFromExpiredToken(token)
+	assertion.Equal(TokenNotValidYet, err)
+}
@@ -0,0 +1,258 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/adamesong/go-util/redis"
+	"github.com/adamesong/go-util/refresh_token"
+	"github.com/golang-jwt/jwt/v5"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// REFRESH_USED_PREFIX refresh token已被轮换的标记，key为refresh token的sha256，value为用户ID和会话ID，用于发现重复使用
+const REFRESH_USED_PREFIX = "jwt_refresh_used:"
+
+var (
+	RefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+	RefreshTokenReused  = errors.New("refresh token has already been used")
+)
+
+// TokenPair 一次签发的access token和refresh token
+type TokenPair struct {
+	AccessToken      string    `json:"access_token"`
+	AccessExpiresAt  time.Time `json:"access_expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	SessionID        string    `json:"session_id"` // 即refresh_token.RefreshToken.ID，轮换时保持不变
+}
+
+// TokenService 签发access token(JWT)和refresh token(不透明的随机字符串)，refresh token保存在refresh_token包的RefreshTokenStore中，
+// 建议用HashedStore包装，只保存哈希。claims的Subject作为用户ID，不能为空。
+// 每次使用refresh token都会换发新的一对token，旧的refresh token随即失效(轮换)，会话ID、创建时间和最长有效期保持不变；
+// 如果已轮换的refresh token再次被使用，说明它可能已被盗用，整个会话(同一次登录产生的所有refresh token)都会被吊销。
+// 已签发的access token不受影响，直到过期，所以AccessDuration应尽量短。
+type TokenService[T any, PT ClaimsPointer[T]] struct {
+	Signer         *Signer[T, PT]
+	Sessions       *refresh_token.SessionManager     // 保存refresh token，可设置MaxSessions；RiskChecker只在RefreshWithFingerprint时使用
+	Config         *refresh_token.RefreshTokenConfig // refresh token的有效期和过期策略，如IdleTimeout、"记住我"
+	Redis          *redis.RedisClient                // 记录已轮换的refresh token，用于发现重复使用；为nil时重复使用只返回RefreshTokenInvalid
+	AccessDuration time.Duration                     // access token有效期，ie: time.Minute * 15
+}
+
+// NewTokenService 新建一个TokenService，refresh token的有效期为refreshDuration，第二个类型参数可以省略
+func NewTokenService[T any, PT ClaimsPointer[T]](j *JwtSign, store refresh_token.RefreshTokenStore, client *redis.RedisClient, accessDuration, refreshDuration time.Duration) *TokenService[T, PT] {
+	return &TokenService[T, PT]{
+		Signer:         NewSigner[T, PT](j),
+		Sessions:       refresh_token.NewSessionManager(store, 0),
+		Config:         &refresh_token.RefreshTokenConfig{Duration: refreshDuration},
+		Redis:          client,
+		AccessDuration: accessDuration,
+	}
+}
+
+// Issue 登录时签发一对新token，开始一个新的会话。claims中的exp、iat、jti由TokenService设置
+func (s *TokenService[T, PT]) Issue(claims T) (*TokenPair, error) {
+	return s.IssueWithOptions(claims, refresh_token.LoginOptions{})
+}
+
+// IssueWithOptions 同Issue，另外记录登录设备并按是否"记住我"选择过期策略，见refresh_token.LoginOptions
+func (s *TokenService[T, PT]) IssueWithOptions(claims T, options refresh_token.LoginOptions) (*TokenPair, error) {
+	token := s.Config.NewRefreshTokenWithOptions(options)
+	if rc := registeredClaims(PT(&claims)); rc != nil {
+		token.UserID = rc.Subject
+	}
+	if token.UserID == "" {
+		return nil, refresh_token.ErrNoUserID
+	}
+	pair, err := s.issue(&token, claims)
+	if err != nil {
+		return nil, err
+	}
+	// access token签发成功后才保存refresh token，失败时不会留下无用的会话
+	if _, err := s.Sessions.CreateSession(token); err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// Refresh 使用refresh token换发一对新token，旧的refresh token随即失效。
+// 如果refresh token已经被使用过，吊销整个会话并返回RefreshTokenReused。不做风险检查，见RefreshWithFingerprint
+func (s *TokenService[T, PT]) Refresh(refreshToken string) (*TokenPair, error) {
+	pair, _, err := s.RefreshWithFingerprint(refreshToken, refresh_token.Fingerprint{})
+	return pair, err
+}
+
+// RefreshWithFingerprint 同Refresh，换发前先用Sessions.RiskChecker检查本次使用的设备，RISK_REJECT时返回refresh_token.ErrRiskRejected，
+// 旧的refresh token仍然有效。新token记录本次使用的ip
+func (s *TokenService[T, PT]) RefreshWithFingerprint(refreshToken string, fingerprint refresh_token.Fingerprint) (*TokenPair, refresh_token.Risk, error) {
+	old, err := s.Sessions.Store.Lookup(refreshToken)
+	if errors.Is(err, refresh_token.ErrTokenNotFound) {
+		return nil, refresh_token.Risk{}, s.checkReuse(refreshToken)
+	}
+	if err != nil {
+		return nil, refresh_token.Risk{}, err
+	}
+	risk, err := s.Sessions.CheckRisk(*old, fingerprint)
+	if err != nil {
+		return nil, risk, err
+	}
+	if fingerprint.IP != "" {
+		old.IP = fingerprint.IP
+		old.Subnet = fingerprint.Subnet
+	}
+	pair, err := s.refresh(refreshToken, old)
+	return pair, risk, err
+}
+
+// refresh 标记refresh token已被使用，然后轮换为新token，old的设备信息会保存到新token中
+func (s *TokenService[T, PT]) refresh(refreshToken string, old *refresh_token.RefreshToken) (*TokenPair, error) {
+	// 用SetNX标记为已使用，并发使用同一个refresh token时，只有一个请求能成功
+	if s.Redis != nil {
+		ttl := time.Until(old.ExpiresAt)
+		if ttl <= 0 {
+			return nil, RefreshTokenInvalid
+		}
+		key := REFRESH_USED_PREFIX + hashRefreshToken(refreshToken)
+		first, err := s.Redis.SetNX(key, old.UserID+"\n"+old.ID, ttl)
+		if err != nil {
+			return nil, err
+		}
+		if !first {
+			return nil, s.revokeReused(old.UserID, old.ID)
+		}
+		pair, err := s.rotate(refreshToken, old)
+		if err != nil {
+			// 轮换失败时删除标记，否则重试会被当作重复使用而吊销整个会话
+			_, _ = s.Redis.Delete(key)
+			return nil, err
+		}
+		return pair, nil
+	}
+	return s.rotate(refreshToken, old)
+}
+
+// rotate 为old换发一对新token，并在store中用新的refresh token替换旧的
+func (s *TokenService[T, PT]) rotate(refreshToken string, old *refresh_token.RefreshToken) (*TokenPair, error) {
+	var claims T
+	if err := json.Unmarshal(old.Data, PT(&claims)); err != nil {
+		return nil, err
+	}
+	// 新token沿用原会话的设备信息，Rotate会保留会话ID和过期策略
+	token := *old
+	token.TokenHash = ""
+	token.LastUsedAt = time.Now()
+	pair, err := s.issue(&token, claims)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Sessions.Store.Rotate(refreshToken, token); err != nil {
+		if errors.Is(err, refresh_token.ErrTokenNotFound) {
+			return nil, RefreshTokenInvalid
+		}
+		return nil, err
+	}
+	// Rotate可能按最长有效期缩短了新token的有效期。轮换已经成功，读取失败时按最长有效期估算，不能让客户端丢掉新token
+	if rotated, err := s.Sessions.Store.Lookup(pair.RefreshToken); err == nil {
+		pair.RefreshExpiresAt = rotated.ExpiresAt
+	} else if !token.MaxExpiresAt.IsZero() && token.MaxExpiresAt.Before(token.ExpiresAt) {
+		pair.RefreshExpiresAt = token.MaxExpiresAt
+	}
+	return pair, nil
+}
+
+// Revoke 退出登录时调用，吊销refresh token所在的会话
+func (s *TokenService[T, PT]) Revoke(refreshToken string) error {
+	err := s.Sessions.Store.Revoke(refreshToken)
+	if errors.Is(err, refresh_token.ErrTokenNotFound) {
+		return RefreshTokenInvalid
+	}
+	return err
+}
+
+// RevokeSession 吊销用户的一个会话，如在"已登录的设备"页面中踢掉某个设备
+func (s *TokenService[T, PT]) RevokeSession(userID, sessionID string) error {
+	return s.Sessions.RevokeSession(userID, sessionID)
+}
+
+// issue 签发access token，并为token生成新的refresh token字符串，保存签发时使用的claims
+func (s *TokenService[T, PT]) issue(token *refresh_token.RefreshToken, claims T) (*TokenPair, error) {
+	now := time.Now()
+
+	// 保存的claims不含时间和jti，换发时重新生成
+	if rc := registeredClaims(PT(&claims)); rc != nil {
+		rc.ID = ""
+		rc.IssuedAt = nil
+		rc.NotBefore = nil
+		rc.ExpiresAt = nil
+	}
+	data, err := json.Marshal(PT(&claims))
+	if err != nil {
+		return nil, err
+	}
+
+	pair := &TokenPair{
+		AccessExpiresAt:  now.Add(s.AccessDuration),
+		RefreshExpiresAt: token.ExpiresAt,
+		SessionID:        token.ID,
+	}
+	if rc := registeredClaims(PT(&claims)); rc != nil {
+		rc.IssuedAt = jwt.NewNumericDate(now)
+		rc.ExpiresAt = jwt.NewNumericDate(pair.AccessExpiresAt)
+	}
+	if pair.AccessToken, err = s.Signer.CreateToken(claims); err != nil {
+		return nil, err
+	}
+	if pair.RefreshToken, err = newOpaqueToken(); err != nil {
+		return nil, err
+	}
+	token.Token = pair.RefreshToken
+	token.Data = data
+	return pair, nil
+}
+
+// checkReuse refresh token不存在时，判断是否是已轮换的token被再次使用，是则吊销其会话并返回RefreshTokenReused
+func (s *TokenService[T, PT]) checkReuse(refreshToken string) error {
+	if s.Redis == nil {
+		return RefreshTokenInvalid
+	}
+	data, err := s.Redis.Get(REFRESH_USED_PREFIX + hashRefreshToken(refreshToken))
+	if errors.Is(err, goredis.Nil) {
+		return RefreshTokenInvalid
+	}
+	if err != nil {
+		return err
+	}
+	userID, sessionID, _ := strings.Cut(string(data), "\n")
+	return s.revokeReused(userID, sessionID)
+}
+
+// revokeReused 吊销重复使用的refresh token所在的会话，返回RefreshTokenReused
+func (s *TokenService[T, PT]) revokeReused(userID, sessionID string) error {
+	err := s.Sessions.RevokeSession(userID, sessionID)
+	if err != nil && !errors.Is(err, refresh_token.ErrSessionNotFound) && !errors.Is(err, refresh_token.ErrTokenNotFound) {
+		return err
+	}
+	return RefreshTokenReused
+}
+
+// newOpaqueToken 生成一个256位的随机refresh token
+func newOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashRefreshToken 已轮换的标记中只保存refresh token的sha256，redis数据泄露时无法直接使用
+func hashRefreshToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,159 @@
+package jwt
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adamesong/go-util/random"
+	"github.com/adamesong/go-util/redis"
+	"github.com/adamesong/go-util/refresh_token"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenService(t *testing.T) {
+	redisClient, err := redis.NewRedisClient("localhost:6379", "", 0)
+	require.NoError(t, err, "Failed to initialize redis client for test")
+	defer redisClient.Close()
+
+	assertion := assert.New(t)
+	hasher, err := refresh_token.NewTokenHasher([]byte(random.RandomString(32)))
+	require.NoError(t, err)
+	store := refresh_token.NewHashedStore(refresh_token.NewRedisStore(redisClient), hasher)
+	service := NewTokenService[tenantClaims](NewJwtSign(random.RandomString(12)), store, redisClient, time.Minute, time.Hour)
+
+	userID := "user-" + random.RandomString(8)
+	claims := tenantClaims{
+		TenantID:         "tenant-1",
+		Roles:            []string{"admin"},
+		RegisteredClaims: jwt.RegisteredClaims{Subject: userID},
+	}
+	pair, err := service.Issue(claims)
+	require.NoError(t, err)
+	assertion.NotEmpty(pair.AccessToken)
+	assertion.NotEmpty(pair.RefreshToken)
+	assertion.WithinDuration(time.Now().Add(time.Hour), pair.RefreshExpiresAt, time.Second)
+
+	access, err := service.Signer.ParseToken(pair.AccessToken)
+	require.NoError(t, err)
+	assertion.Equal("tenant-1", access.TenantID)
+	assertion.Equal(userID, access.Subject)
+	assertion.WithinDuration(pair.AccessExpiresAt, access.ExpiresAt.Time, time.Second)
+
+	// refresh token保存在refresh_token的store中，只保存哈希
+	sessions, err := service.Sessions.ListSessions(userID, pair.RefreshToken)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assertion.Equal(pair.SessionID, sessions[0].ID)
+	assertion.True(sessions[0].Current)
+
+	// 轮换：换发新token，claims和会话ID保持不变
+	rotated, err := service.Refresh(pair.RefreshToken)
+	require.NoError(t, err)
+	assertion.NotEqual(pair.RefreshToken, rotated.RefreshToken)
+	assertion.Equal(pair.SessionID, rotated.SessionID)
+	assertion.False(rotated.RefreshExpiresAt.After(pair.RefreshExpiresAt))
+	access, err = service.Signer.ParseToken(rotated.AccessToken)
+	require.NoError(t, err)
+	assertion.Equal("tenant-1", access.TenantID)
+	assertion.Equal([]string{"admin"}, access.Roles)
+
+	// 重复使用已轮换的refresh token，整个会话被吊销
+	_, err = service.Refresh(pair.RefreshToken)
+	assertion.Equal(RefreshTokenReused, err)
+	_, err = service.Refresh(rotated.RefreshToken)
+	assertion.Equal(RefreshTokenInvalid, err)
+	sessions, err = service.Sessions.ListSessions(userID, "")
+	require.NoError(t, err)
+	assertion.Empty(sessions)
+
+	// 不存在的refresh token
+	_, err = service.Refresh("not-a-refresh-token")
+	assertion.Equal(RefreshTokenInvalid, err)
+
+	// 退出登录后refresh token失效，其他登录不受影响
+	first, err := service.Issue(claims)
+	require.NoError(t, err)
+	second, err := service.Issue(claims)
+	require.NoError(t, err)
+	require.NoError(t, service.Revoke(first.RefreshToken))
+	_, err = service.Refresh(first.RefreshToken)
+	assertion.Equal(RefreshTokenInvalid, err)
+	_, err = service.Refresh(second.RefreshToken)
+	assertion.NoError(err)
+
+	// 没有用户ID时不签发
+	_, err = service.Issue(tenantClaims{TenantID: "tenant-1"})
+	assertion.Equal(refresh_token.ErrNoUserID, err)
+	_, err = service.Issue(claims)
+	assertion.NoError(err)
+}
+
+// flakyStore Rotate第一次调用时失败
+type flakyStore struct {
+	refresh_token.RefreshTokenStore
+	failed bool
+}
+
+func (s *flakyStore) Rotate(oldToken string, newToken refresh_token.RefreshToken) error {
+	if !s.failed {
+		s.failed = true
+		return errors.New("store unavailable")
+	}
+	return s.RefreshTokenStore.Rotate(oldToken, newToken)
+}
+
+func TestTokenServiceRefreshRetry(t *testing.T) {
+	redisClient, err := redis.NewRedisClient("localhost:6379", "", 0)
+	require.NoError(t, err, "Failed to initialize redis client for test")
+	defer redisClient.Close()
+
+	assertion := assert.New(t)
+	store := &flakyStore{RefreshTokenStore: refresh_token.NewRedisStore(redisClient)}
+	service := NewTokenService[tenantClaims](NewJwtSign(random.RandomString(12)), store, redisClient, time.Minute, time.Hour)
+
+	userID := "user-" + random.RandomString(8)
+	pair, err := service.Issue(tenantClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: userID}})
+	require.NoError(t, err)
+
+	// 轮换失败后重试不会被当作重复使用
+	_, err = service.Refresh(pair.RefreshToken)
+	assertion.EqualError(err, "store unavailable")
+	rotated, err := service.Refresh(pair.RefreshToken)
+	require.NoError(t, err)
+	assertion.Equal(pair.SessionID, rotated.SessionID)
+	_, err = service.Refresh(rotated.RefreshToken)
+	assertion.NoError(err)
+}
+
+func TestTokenServiceRefreshWithFingerprint(t *testing.T) {
+	assertion := assert.New(t)
+	service := NewTokenService[tenantClaims](NewJwtSign(random.RandomString(12)), refresh_token.NewMemoryStore(), nil, time.Minute, time.Hour)
+	var events []refresh_token.RiskEvent
+	service.Sessions.RiskChecker = refresh_token.NewDeviceRiskChecker(refresh_token.RISK_FLAG)
+	service.Sessions.OnRisk = func(event refresh_token.RiskEvent) { events = append(events, event) }
+
+	userAgent := "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36"
+	pair, err := service.IssueWithOptions(tenantClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "user-1"}},
+		refresh_token.LoginOptions{IP: "203.0.113.5", UserAgent: userAgent, ClientKey: "key-1"})
+	require.NoError(t, err)
+
+	// 客户端key不同，拒绝换发，原refresh token仍然有效
+	_, risk, err := service.RefreshWithFingerprint(pair.RefreshToken, refresh_token.NewFingerprint(userAgent, "203.0.113.5", "key-2"))
+	assertion.Equal(refresh_token.ErrRiskRejected, err)
+	assertion.Equal(refresh_token.RISK_REJECT, risk.Level)
+	assertion.Len(events, 1)
+
+	// 新网段只标记，新token记录新的ip
+	rotated, risk, err := service.RefreshWithFingerprint(pair.RefreshToken, refresh_token.NewFingerprint(userAgent, "198.51.100.7", "key-1"))
+	require.NoError(t, err)
+	assertion.Equal(refresh_token.RISK_FLAG, risk.Level)
+	assertion.Equal([]string{refresh_token.RISK_REASON_NEW_NETWORK}, risk.Reasons)
+	assertion.Len(events, 2)
+	sessions, err := service.Sessions.ListSessions("user-1", rotated.RefreshToken)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assertion.Equal("198.51.100.7", sessions[0].IP)
+}
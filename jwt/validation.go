@@ -0,0 +1,94 @@
+package jwt
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ValidationOptions 解析token时对claims的额外校验，多个服务共用一个secret时，至少应校验Issuer和Audiences
+type ValidationOptions struct {
+	Issuer            string        // 期望的iss，为空时不校验
+	Audiences         []string      // 可接受的aud，token的aud中包含其中任意一个即可，为空时不校验
+	Leeway            time.Duration // 校验exp、nbf、iat时允许的时钟误差
+	RequireExpiration bool          // token必须包含exp
+	RequireIssuedAt   bool          // token必须包含iat，且iat不能晚于当前时间
+	RequireSubject    bool          // token必须包含sub
+	ValidMethods      []string      // 允许的签名算法，如 []string{"RS256", "ES256"}，为空时不限制
+}
+
+// methodAllowed 签名算法是否在允许范围内，v为nil时不限制
+func (v *ValidationOptions) methodAllowed(alg string) bool {
+	if v == nil || len(v.ValidMethods) == 0 {
+		return true
+	}
+	return slices.Contains(v.ValidMethods, alg)
+}
+
+// parserOptions 交给jwt库校验的部分：时钟误差，以及iat是否晚于当前时间
+func (v *ValidationOptions) parserOptions() []jwt.ParserOption {
+	if v == nil {
+		return nil
+	}
+	options := []jwt.ParserOption{jwt.WithLeeway(v.Leeway)}
+	if v.RequireIssuedAt {
+		options = append(options, jwt.WithIssuedAt())
+	}
+	return options
+}
+
+// validate 校验iss、aud以及必须包含的claims，每种失败返回不同的错误
+func (v *ValidationOptions) validate(claims jwt.Claims) error {
+	if v == nil {
+		return nil
+	}
+	if v.Issuer != "" {
+		if issuer, err := claims.GetIssuer(); err != nil || issuer != v.Issuer {
+			return TokenInvalidIssuer
+		}
+	}
+	if len(v.Audiences) > 0 {
+		audiences, err := claims.GetAudience()
+		if err != nil || !slices.ContainsFunc(audiences, func(aud string) bool {
+			return slices.Contains(v.Audiences, aud)
+		}) {
+			return TokenInvalidAudience
+		}
+	}
+	if v.RequireExpiration {
+		if exp, err := claims.GetExpirationTime(); err != nil || exp == nil {
+			return TokenMissingExpiration
+		}
+	}
+	if v.RequireIssuedAt {
+		if iat, err := claims.GetIssuedAt(); err != nil || iat == nil {
+			return TokenMissingIssuedAt
+		}
+	}
+	if v.RequireSubject {
+		if sub, err := claims.GetSubject(); err != nil || sub == "" {
+			return TokenMissingSubject
+		}
+	}
+	return nil
+}
+
+// convertError 将jwt库解析token的错误转换为本包的错误
+func convertError(err error) error {
+	switch {
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return TokenMalformed
+	case errors.Is(err, TokenInvalidSigningMethod):
+		return TokenInvalidSigningMethod
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return TokenExpired
+	case errors.Is(err, jwt.ErrTokenNotValidYet):
+		return TokenNotValidYet
+	case errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return TokenUsedBeforeIssued
+	default:
+		return TokenInvalid
+	}
+}
@@ -0,0 +1,122 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/adamesong/go-util/random"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidationOptions(t *testing.T) {
+	jwtSign := NewJwtSign(random.RandomString(12))
+	jwtSign.Validation = &ValidationOptions{
+		Issuer:            "auth-service",
+		Audiences:         []string{"api", "admin"},
+		RequireExpiration: true,
+		RequireIssuedAt:   true,
+		RequireSubject:    true,
+	}
+
+	valid := func() CustomClaims {
+		return CustomClaims{
+			UserID: "1",
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    "auth-service",
+				Audience:  jwt.ClaimStrings{"admin"},
+				Subject:   "1",
+				IssuedAt:  jwt.NewNumericDate(time.Now()),
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			},
+		}
+	}
+
+	cases := []struct {
+		name   string
+		modify func(claims *CustomClaims)
+		err    error
+	}{
+		{"valid", func(claims *CustomClaims) {}, nil},
+		{"wrong issuer", func(claims *CustomClaims) { claims.Issuer = "other-service" }, TokenInvalidIssuer},
+		{"missing issuer", func(claims *CustomClaims) { claims.Issuer = "" }, TokenInvalidIssuer},
+		{"wrong audience", func(claims *CustomClaims) { claims.Audience = jwt.ClaimStrings{"web"} }, TokenInvalidAudience},
+		{"missing audience", func(claims *CustomClaims) { claims.Audience = nil }, TokenInvalidAudience},
+		{"missing exp", func(claims *CustomClaims) { claims.ExpiresAt = nil }, TokenMissingExpiration},
+		{"missing iat", func(claims *CustomClaims) { claims.IssuedAt = nil }, TokenMissingIssuedAt},
+		{"missing sub", func(claims *CustomClaims) { claims.Subject = "" }, TokenMissingSubject},
+		{"iat in future", func(claims *CustomClaims) {
+			claims.IssuedAt = jwt.NewNumericDate(time.Now().Add(time.Minute))
+		}, TokenUsedBeforeIssued},
+		{"expired", func(claims *CustomClaims) {
+			claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+		}, TokenExpired},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			claims := valid()
+			c.modify(&claims)
+			// 直接签名，CreateToken会自动补上iat
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSign.SigningKey)
+			require.NoError(t, err)
+			_, err = jwtSign.ParseToken(token)
+			assert.Equal(t, c.err, err)
+		})
+	}
+}
+
+func TestValidationLeeway(t *testing.T) {
+	jwtSign := NewJwtSign(random.RandomString(12))
+	claims := newTestClaims()
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-10 * time.Second))
+	token, err := jwtSign.CreateToken(claims)
+	require.NoError(t, err)
+
+	_, err = jwtSign.ParseToken(token)
+	assert.Equal(t, TokenExpired, err)
+
+	jwtSign.Validation = &ValidationOptions{Leeway: time.Minute}
+	_, err = jwtSign.ParseToken(token)
+	assert.NoError(t, err)
+}
+
+func TestValidationExpiredToken(t *testing.T) {
+	jwtSign := NewJwtSign(random.RandomString(12))
+	jwtSign.Validation = &ValidationOptions{Issuer: "auth-service"}
+
+	claims := newTestClaims()
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	claims.Issuer = "auth-service"
+	token, err := jwtSign.CreateToken(claims)
+	require.NoError(t, err)
+	expired, err := jwtSign.GetClaimsFromExpiredToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, claims.UserID, expired.UserID)
+
+	// 过期且iss不符时，不能取出claims
+	claims.Issuer = "other-service"
+	token, err = jwtSign.CreateToken(claims)
+	require.NoError(t, err)
+	_, err = jwtSign.GetClaimsFromExpiredToken(token)
+	assert.Equal(t, TokenInvalidIssuer, err)
+}
+
+func TestValidationSigningMethods(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	jwtSign, err := NewJwtSignWithKeys("ed", NewEdDSAKey("ed", edKey))
+	require.NoError(t, err)
+	token, err := jwtSign.CreateToken(newTestClaims())
+	require.NoError(t, err)
+
+	jwtSign.Validation = &ValidationOptions{ValidMethods: []string{"RS256", "ES256"}}
+	_, err = jwtSign.ParseToken(token)
+	assert.Equal(t, TokenInvalidSigningMethod, err)
+
+	jwtSign.Validation.ValidMethods = append(jwtSign.Validation.ValidMethods, "EdDSA")
+	_, err = jwtSign.ParseToken(token)
+	assert.NoError(t, err)
+}
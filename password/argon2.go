@@ -0,0 +1,137 @@
+package password
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// argon2 的默认参数，与 django 的 Argon2PasswordHasher 一致
+const (
+	DEFAULT_ARGON2_TIME        = 2
+	DEFAULT_ARGON2_MEMORY      = 102400 // KiB
+	DEFAULT_ARGON2_PARALLELISM = 8
+	DEFAULT_ARGON2_KEY_LENGTH  = 32
+)
+
+// Argon2Hasher argon2id，与 django 的 Argon2PasswordHasher 格式兼容：
+// argon2$argon2id$v=19$m=102400,t=2,p=8$<盐>$<哈希>，盐和哈希为不带padding的base64
+type Argon2Hasher struct {
+	Time        uint32 // 迭代次数，0为默认值
+	Memory      uint32 // 内存(KiB)，0为默认值
+	Parallelism uint8  // 并行度，0为默认值
+}
+
+// argon2Params encoded 中 argon2 的参数
+type argon2Params struct {
+	variant     string
+	version     int
+	memory      uint32
+	time        uint32
+	parallelism uint8
+	salt        []byte
+	hash        []byte
+}
+
+func (h *Argon2Hasher) Algorithm() string {
+	return "argon2"
+}
+
+func (h *Argon2Hasher) Encode(password string) (string, error) {
+	salt, err := newSalt()
+	if err != nil {
+		return "", err
+	}
+	params := argon2Params{
+		variant:     "argon2id",
+		version:     argon2.Version,
+		memory:      h.memory(),
+		time:        h.time(),
+		parallelism: h.parallelism(),
+		salt:        []byte(salt),
+	}
+	params.hash = argon2.IDKey([]byte(password), params.salt, params.time, params.memory, params.parallelism, DEFAULT_ARGON2_KEY_LENGTH)
+	return h.Algorithm() + params.String(), nil
+}
+
+func (h *Argon2Hasher) Verify(password, encoded string) bool {
+	data, ok := strings.CutPrefix(encoded, h.Algorithm()+"$")
+	if !ok {
+		return false
+	}
+	params, err := parseArgon2("$" + data)
+	if err != nil || params.variant != "argon2id" || params.version != argon2.Version {
+		return false
+	}
+	hash := argon2.IDKey([]byte(password), params.salt, params.time, params.memory, params.parallelism, uint32(len(params.hash)))
+	return subtle.ConstantTimeCompare(hash, params.hash) == 1
+}
+
+// MustUpdate argon2 的类型、版本或参数与当前设置不同时需要更新
+func (h *Argon2Hasher) MustUpdate(encoded string) bool {
+	data, ok := strings.CutPrefix(encoded, h.Algorithm()+"$")
+	if !ok {
+		return false
+	}
+	params, err := parseArgon2("$" + data)
+	if err != nil {
+		return false
+	}
+	return params.variant != "argon2id" || params.version != argon2.Version ||
+		params.time != h.time() || params.memory != h.memory() || params.parallelism != h.parallelism()
+}
+
+func (h *Argon2Hasher) time() uint32 {
+	if h.Time == 0 {
+		return DEFAULT_ARGON2_TIME
+	}
+	return h.Time
+}
+
+func (h *Argon2Hasher) memory() uint32 {
+	if h.Memory == 0 {
+		return DEFAULT_ARGON2_MEMORY
+	}
+	return h.Memory
+}
+
+func (h *Argon2Hasher) parallelism() uint8 {
+	if h.Parallelism == 0 {
+		return DEFAULT_ARGON2_PARALLELISM
+	}
+	return h.Parallelism
+}
+
+// String argon2 的标准格式 $argon2id$v=19$m=...,t=...,p=...$<盐>$<哈希>
+func (p argon2Params) String() string {
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s", p.variant, p.version, p.memory, p.time, p.parallelism,
+		base64.RawStdEncoding.EncodeToString(p.salt), base64.RawStdEncoding.EncodeToString(p.hash))
+}
+
+// parseArgon2 解析 argon2 的标准格式
+func parseArgon2(data string) (params argon2Params, err error) {
+	parts := strings.Split(data, "$")
+	if len(parts) != 6 || parts[0] != "" {
+		return params, ErrInvalidEncoded
+	}
+	params.variant = parts[1]
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &params.version); err != nil {
+		return params, ErrInvalidEncoded
+	}
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.parallelism); err != nil {
+		return params, ErrInvalidEncoded
+	}
+	if params.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return params, ErrInvalidEncoded
+	}
+	if params.hash, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(params.hash) == 0 {
+		return params, ErrInvalidEncoded
+	}
+	if params.time == 0 || params.parallelism == 0 {
+		return params, ErrInvalidEncoded
+	}
+	return params, nil
+}
@@ -0,0 +1,121 @@
+package password
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DEFAULT_BCRYPT_COST bcrypt 的默认 cost，与 django 的 BCryptSHA256PasswordHasher 一致
+const DEFAULT_BCRYPT_COST = 12
+
+// BCryptSHA256Hasher 先对密码做 sha256 再 bcrypt，没有 bcrypt 72 字节的长度限制。
+// 与 django 的 BCryptSHA256PasswordHasher 格式兼容：bcrypt_sha256$$2b$12$...
+type BCryptSHA256Hasher struct {
+	Cost int // 0为默认值
+}
+
+func (h *BCryptSHA256Hasher) Algorithm() string {
+	return "bcrypt_sha256"
+}
+
+func (h *BCryptSHA256Hasher) Encode(password string) (string, error) {
+	return encodeBcrypt(h.Algorithm(), sha256Hex(password), h.Cost)
+}
+
+func (h *BCryptSHA256Hasher) Verify(password, encoded string) bool {
+	return verifyBcrypt(h.Algorithm(), sha256Hex(password), encoded)
+}
+
+func (h *BCryptSHA256Hasher) MustUpdate(encoded string) bool {
+	return mustUpdateBcrypt(h.Algorithm(), encoded, h.Cost)
+}
+
+func (h *BCryptSHA256Hasher) HardenRuntime(password, encoded string) {
+	hardenBcrypt(h.Algorithm(), sha256Hex(password), encoded, h.Cost)
+}
+
+// BCryptHasher 直接 bcrypt，超过72字节的密码无法使用，建议使用 BCryptSHA256Hasher。
+// 与 django 的 BCryptPasswordHasher 格式兼容：bcrypt$$2b$12$...
+type BCryptHasher struct {
+	Cost int // 0为默认值
+}
+
+func (h *BCryptHasher) Algorithm() string {
+	return "bcrypt"
+}
+
+func (h *BCryptHasher) Encode(password string) (string, error) {
+	return encodeBcrypt(h.Algorithm(), password, h.Cost)
+}
+
+func (h *BCryptHasher) Verify(password, encoded string) bool {
+	return verifyBcrypt(h.Algorithm(), password, encoded)
+}
+
+func (h *BCryptHasher) MustUpdate(encoded string) bool {
+	return mustUpdateBcrypt(h.Algorithm(), encoded, h.Cost)
+}
+
+func (h *BCryptHasher) HardenRuntime(password, encoded string) {
+	hardenBcrypt(h.Algorithm(), password, encoded, h.Cost)
+}
+
+// encodeBcrypt bcrypt 自带随机的盐，encoded 为 algorithm$ 加上 bcrypt 的结果
+func encodeBcrypt(algorithm, password string, cost int) (string, error) {
+	data, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost(cost))
+	if err != nil {
+		return "", err
+	}
+	return algorithm + "$" + string(data), nil
+}
+
+func verifyBcrypt(algorithm, password, encoded string) bool {
+	data, ok := strings.CutPrefix(encoded, algorithm+"$")
+	if !ok {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(data), []byte(password)) == nil
+}
+
+// mustUpdateBcrypt cost 与当前设置不同时需要更新
+func mustUpdateBcrypt(algorithm, encoded string, cost int) bool {
+	data, ok := strings.CutPrefix(encoded, algorithm+"$")
+	if !ok {
+		return false
+	}
+	encodedCost, err := bcrypt.Cost([]byte(data))
+	return err == nil && encodedCost != bcryptCost(cost)
+}
+
+// hardenBcrypt bcrypt 的耗时随 cost 指数增长，补足 2^cost - 2^encodedCost 次的计算量，与 django 一致
+func hardenBcrypt(algorithm, password, encoded string, cost int) {
+	data, ok := strings.CutPrefix(encoded, algorithm+"$")
+	if !ok {
+		return
+	}
+	encodedCost, err := bcrypt.Cost([]byte(data))
+	if err != nil {
+		return
+	}
+	diff := 1<<bcryptCost(cost) - 1<<encodedCost
+	for diff > 0 && encodedCost >= bcrypt.MinCost {
+		bcrypt.GenerateFromPassword([]byte(password), encodedCost)
+		diff -= 1 << encodedCost
+	}
+}
+
+func bcryptCost(cost int) int {
+	if cost == 0 {
+		return DEFAULT_BCRYPT_COST
+	}
+	return cost
+}
+
+// sha256Hex 与 django 一样使用 sha256 的 hex 作为 bcrypt 的输入
+func sha256Hex(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,265 @@
+package password
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 泄露密码检查使用 k-anonymity 的 range 协议：只把密码 SHA-1 的前5位发给服务端，服务端返回所有以此开头的哈希的后35位及泄露次数
+const (
+	PWNED_PASSWORDS_RANGE_URL = "https://api.pwnedpasswords.com/range/"
+	RANGE_PREFIX_LENGTH       = 5
+	DEFAULT_BREACH_TIMEOUT    = 3 * time.Second
+	CODE_PASSWORD_BREACHED    = "password_breached"
+)
+
+var ErrInvalidRangePrefix = errors.New("range prefix must be 5 hex characters")
+
+// RangeSource 根据 SHA-1 的前5位(大写hex)，返回以此开头的泄露哈希的后35位(大写hex)及泄露次数
+type RangeSource interface {
+	Range(ctx context.Context, prefix string) (map[string]int, error)
+}
+
+// BreachCount 密码在source中的泄露次数，0为没有泄露
+func BreachCount(ctx context.Context, source RangeSource, password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	suffixes, err := source.Range(ctx, hash[:RANGE_PREFIX_LENGTH])
+	if err != nil {
+		return 0, err
+	}
+	return suffixes[hash[RANGE_PREFIX_LENGTH:]], nil
+}
+
+// BreachedPasswordValidator 密码不能是已泄露的密码
+type BreachedPasswordValidator struct {
+	Source       RangeSource
+	MinCount     int           // 泄露次数达到该值时校验不通过，0为1
+	Timeout      time.Duration // 查询的超时时间，0为DEFAULT_BREACH_TIMEOUT
+	IgnoreErrors bool          // 查询失败时是否放行，为false时返回查询的错误
+}
+
+// NewBreachedPasswordValidator 使用公开的 range API 检查
+func NewBreachedPasswordValidator() *BreachedPasswordValidator {
+	return &BreachedPasswordValidator{Source: &HTTPRangeSource{}}
+}
+
+func (v *BreachedPasswordValidator) Validate(password string, user UserAttributes) error {
+	timeout := v.Timeout
+	if timeout == 0 {
+		timeout = DEFAULT_BREACH_TIMEOUT
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	count, err := BreachCount(ctx, v.Source, password)
+	if err != nil {
+		if v.IgnoreErrors {
+			return nil
+		}
+		return err
+	}
+	minCount := v.MinCount
+	if minCount == 0 {
+		minCount = 1
+	}
+	if count < minCount {
+		return nil
+	}
+	return &ValidationError{
+		Code:    CODE_PASSWORD_BREACHED,
+		Message: "This password has appeared in a data breach and cannot be used.",
+		Params:  map[string]any{"count": count},
+	}
+}
+
+// HTTPRangeSource 通过 HTTP 查询 range API，默认为 Have I Been Pwned 的 Pwned Passwords
+type HTTPRangeSource struct {
+	URL        string       // range API 的地址，前缀拼接在其后，为空时使用PWNED_PASSWORDS_RANGE_URL
+	HTTPClient *http.Client // 为nil时使用http.DefaultClient
+	UserAgent  string
+	AddPadding bool // 请求服务端返回随机的填充数据(次数为0)，使响应的大小不泄露前缀
+}
+
+func (s *HTTPRangeSource) Range(ctx context.Context, prefix string) (map[string]int, error) {
+	if !isRangePrefix(prefix) {
+		return nil, ErrInvalidRangePrefix
+	}
+	url := s.URL
+	if url == "" {
+		url = PWNED_PASSWORDS_RANGE_URL
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url+prefix, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.UserAgent != "" {
+		request.Header.Set("User-Agent", s.UserAgent)
+	}
+	if s.AddPadding {
+		request.Header.Set("Add-Padding", "true")
+	}
+	client := s.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("password: range request failed: %s", response.Status)
+	}
+	return parseRange(response.Body, "")
+}
+
+// DirRangeSource 离线的 range 数据：目录中每个前缀一个文件，文件名为前缀加 .txt(如 5BAA6.txt)，
+// 内容与 range API 的响应相同，每行为 后35位:次数。如 haveibeenpwned-downloader 生成的文件
+type DirRangeSource struct {
+	Dir string
+}
+
+func (s *DirRangeSource) Range(ctx context.Context, prefix string) (map[string]int, error) {
+	if !isRangePrefix(prefix) {
+		return nil, ErrInvalidRangePrefix
+	}
+	file, err := os.Open(filepath.Join(s.Dir, strings.ToUpper(prefix)+".txt"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return map[string]int{}, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+	return parseRange(file, "")
+}
+
+// SortedFileRangeSource 离线的 range 数据：一个按哈希排序的文件，每行为 完整的40位SHA-1:次数，
+// 如 pwned-passwords-sha1-ordered-by-hash.txt。使用二分查找，不需要把文件读入内存
+type SortedFileRangeSource struct {
+	Path string
+}
+
+func (s *SortedFileRangeSource) Range(ctx context.Context, prefix string) (map[string]int, error) {
+	if !isRangePrefix(prefix) {
+		return nil, ErrInvalidRangePrefix
+	}
+	prefix = strings.ToUpper(prefix)
+	file, err := os.Open(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+
+	// 二分查找第一个哈希不小于prefix的行：起始位置在lo之前的行都小于prefix
+	lo, hi := int64(0), size
+	for lo < hi {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		mid := (lo + hi) / 2
+		start, line, err := lineFrom(file, mid, size)
+		if err != nil {
+			return nil, err
+		}
+		if start >= hi || line == "" {
+			hi = mid
+			continue
+		}
+		if strings.ToUpper(firstN(line, RANGE_PREFIX_LENGTH)) < prefix {
+			lo = start + int64(len(line)) + 1
+		} else {
+			hi = mid
+		}
+	}
+	start, _, err := lineFrom(file, lo, size)
+	if err != nil {
+		return nil, err
+	}
+	return parseRange(io.NewSectionReader(file, start, size-start), prefix)
+}
+
+// lineFrom 读取起始位置不小于offset的第一行，返回其起始位置和内容(不含换行)，没有时line为空
+func lineFrom(file *os.File, offset, size int64) (start int64, line string, err error) {
+	start = offset
+	if offset > 0 {
+		// offset-1处是换行时，offset就是一行的开头
+		reader := bufio.NewReader(io.NewSectionReader(file, offset-1, size-offset+1))
+		skipped, err := reader.ReadString('\n')
+		if err == io.EOF {
+			return size, "", nil
+		}
+		if err != nil {
+			return 0, "", err
+		}
+		start = offset - 1 + int64(len(skipped))
+	}
+	reader := bufio.NewReader(io.NewSectionReader(file, start, size-start))
+	line, err = reader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return 0, "", err
+	}
+	return start, strings.TrimRight(line, "\r\n"), nil
+}
+
+// parseRange 解析 哈希:次数 格式的行。prefix为空时每行为后35位；不为空时每行为完整的哈希，
+// 只读取以prefix开头的连续行(文件已排序，遇到其他前缀即停止)，并去掉前缀
+func parseRange(r io.Reader, prefix string) (map[string]int, error) {
+	suffixes := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		hash, countString, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok {
+			continue
+		}
+		hash = strings.ToUpper(hash)
+		if prefix != "" {
+			suffix, ok := strings.CutPrefix(hash, prefix)
+			if !ok {
+				break
+			}
+			hash = suffix
+		}
+		count, err := strconv.Atoi(countString)
+		if err != nil {
+			return nil, fmt.Errorf("password: invalid range line %q", scanner.Text())
+		}
+		suffixes[hash] = count
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return suffixes, nil
+}
+
+// isRangePrefix 是否是5位hex
+func isRangePrefix(prefix string) bool {
+	if len(prefix) != RANGE_PREFIX_LENGTH {
+		return false
+	}
+	_, err := hex.DecodeString(prefix + "0")
+	return err == nil
+}
+
+func firstN(s string, n int) string {
+	if len(s) < n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,132 @@
+package password
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sha1Upper 密码的大写SHA-1
+func sha1Upper(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// breachedCorpus 测试用的泄露密码哈希及次数，包含大量其他哈希
+func breachedCorpus() map[string]int {
+	corpus := map[string]int{sha1Upper("password"): 10434004, sha1Upper("hunter2"): 42}
+	for i := 0; i < 2000; i++ {
+		corpus[sha1Upper(fmt.Sprintf("filler-%d", i))] = i + 1
+	}
+	return corpus
+}
+
+func TestHTTPRangeSource(t *testing.T) {
+	assertion := assert.New(t)
+	corpus := breachedCorpus()
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := strings.TrimPrefix(r.URL.Path, "/range/")
+		requested = append(requested, prefix)
+		assertion.Equal("true", r.Header.Get("Add-Padding"))
+		for hash, count := range corpus {
+			if strings.HasPrefix(hash, prefix) {
+				fmt.Fprintf(w, "%s:%d\r\n", hash[RANGE_PREFIX_LENGTH:], count)
+			}
+		}
+		fmt.Fprintf(w, "%s:0\r\n", strings.Repeat("F", 35)) // 填充
+	}))
+	defer server.Close()
+
+	source := &HTTPRangeSource{URL: server.URL + "/range/", AddPadding: true}
+	count, err := BreachCount(context.Background(), source, "password")
+	require.NoError(t, err)
+	assertion.Equal(10434004, count)
+	// 只发送了前5位
+	assertion.Equal([]string{sha1Upper("password")[:5]}, requested)
+
+	count, err = BreachCount(context.Background(), source, "a-password-nobody-uses-7f3a")
+	require.NoError(t, err)
+	assertion.Zero(count)
+
+	_, err = source.Range(context.Background(), "XYZ12")
+	assertion.Equal(ErrInvalidRangePrefix, err)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	}))
+	defer failing.Close()
+	validator := &BreachedPasswordValidator{Source: &HTTPRangeSource{URL: failing.URL + "/"}}
+	assertion.Error(validator.Validate("password", nil))
+	validator.IgnoreErrors = true
+	assertion.NoError(validator.Validate("password", nil))
+}
+
+func TestOfflineRangeSources(t *testing.T) {
+	corpus := breachedCorpus()
+	hashes := make([]string, 0, len(corpus))
+	for hash := range corpus {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+
+	dir := t.TempDir()
+	sortedFile := filepath.Join(dir, "pwned-passwords-sha1-ordered-by-hash.txt")
+	var sorted strings.Builder
+	prefixFiles := make(map[string]*strings.Builder)
+	for _, hash := range hashes {
+		fmt.Fprintf(&sorted, "%s:%d\r\n", hash, corpus[hash])
+		prefix := hash[:RANGE_PREFIX_LENGTH]
+		if prefixFiles[prefix] == nil {
+			prefixFiles[prefix] = &strings.Builder{}
+		}
+		fmt.Fprintf(prefixFiles[prefix], "%s:%d\n", hash[RANGE_PREFIX_LENGTH:], corpus[hash])
+	}
+	require.NoError(t, os.WriteFile(sortedFile, []byte(sorted.String()), 0o644))
+	rangeDir := filepath.Join(dir, "ranges")
+	require.NoError(t, os.Mkdir(rangeDir, 0o755))
+	for prefix, content := range prefixFiles {
+		require.NoError(t, os.WriteFile(filepath.Join(rangeDir, prefix+".txt"), []byte(content.String()), 0o644))
+	}
+
+	sources := map[string]RangeSource{
+		"sorted": &SortedFileRangeSource{Path: sortedFile},
+		"dir":    &DirRangeSource{Dir: rangeDir},
+	}
+	for name, source := range sources {
+		t.Run(name, func(t *testing.T) {
+			assertion := assert.New(t)
+			// 第一个、最后一个及所有哈希都能找到
+			for _, hash := range hashes {
+				suffixes, err := source.Range(context.Background(), hash[:RANGE_PREFIX_LENGTH])
+				require.NoError(t, err)
+				require.Equal(t, corpus[hash], suffixes[hash[RANGE_PREFIX_LENGTH:]], hash)
+			}
+			suffixes, err := source.Range(context.Background(), "00000")
+			require.NoError(t, err)
+			assertion.Empty(suffixes)
+			suffixes, err = source.Range(context.Background(), "fffff")
+			require.NoError(t, err)
+			assertion.Empty(suffixes)
+
+			validator := &BreachedPasswordValidator{Source: source}
+			err = validator.Validate("hunter2", nil)
+			require.Error(t, err)
+			assertion.Equal(map[string]any{"count": 42}, err.(*ValidationError).Params)
+			assertion.NoError(validator.Validate("a-password-nobody-uses-7f3a", nil))
+			validator.MinCount = 100
+			assertion.NoError(validator.Validate("hunter2", nil))
+		})
+	}
+}
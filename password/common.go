@@ -0,0 +1,91 @@
+package password
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// commonPasswordsGz 最常见的20000个密码(小写，每行一个)，取自 zxcvbn 的 passwords 频率表 (MIT License, Copyright (c) 2012-2016 Dan Wheeler and Dropbox, Inc.)
+//
+//go:embed common-passwords.txt.gz
+var commonPasswordsGz []byte
+
+var (
+	defaultCommonPasswords     map[string]struct{}
+	defaultCommonPasswordsOnce sync.Once
+)
+
+// CommonPasswordValidator 密码不能是常见密码，对应 django 的 CommonPasswordValidator
+type CommonPasswordValidator struct {
+	Passwords map[string]struct{} // 常见密码(小写)，为nil时使用内置的20000个常见密码
+}
+
+// NewCommonPasswordValidatorFromFile 使用自定义的密码列表文件，每行一个密码，可以是gzip压缩的
+func NewCommonPasswordValidatorFromFile(path string) (*CommonPasswordValidator, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	passwords, err := LoadCommonPasswords(file)
+	if err != nil {
+		return nil, err
+	}
+	return &CommonPasswordValidator{Passwords: passwords}, nil
+}
+
+func (v *CommonPasswordValidator) Validate(password string, user UserAttributes) error {
+	passwords := v.Passwords
+	if passwords == nil {
+		passwords = defaultPasswords()
+	}
+	if _, ok := passwords[strings.TrimSpace(strings.ToLower(password))]; !ok {
+		return nil
+	}
+	return &ValidationError{
+		Code:    CODE_PASSWORD_TOO_COMMON,
+		Message: "This password is too common.",
+	}
+}
+
+// LoadCommonPasswords 读取密码列表，每行一个密码，自动识别gzip压缩
+func LoadCommonPasswords(r io.Reader) (map[string]struct{}, error) {
+	reader := bufio.NewReader(r)
+	if magic, err := reader.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = bufio.NewReader(gz)
+	}
+	passwords := make(map[string]struct{})
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		if password := strings.TrimSpace(strings.ToLower(scanner.Text())); password != "" {
+			passwords[password] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return passwords, nil
+}
+
+// defaultPasswords 内置的常见密码，第一次使用时解压
+func defaultPasswords() map[string]struct{} {
+	defaultCommonPasswordsOnce.Do(func() {
+		passwords, err := LoadCommonPasswords(bytes.NewReader(commonPasswordsGz))
+		if err != nil {
+			panic("password: invalid embedded common password list: " + err.Error())
+		}
+		defaultCommonPasswords = passwords
+	})
+	return defaultCommonPasswords
+}
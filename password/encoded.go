@@ -0,0 +1,194 @@
+package password
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// EncodedPassword encoded 解析后的各个部分，对应 django hasher 的 decode()。
+// Salt 和 Hash 保持 encoded 中的原样(pbkdf2、scrypt 的哈希为 base64，argon2 为不带padding的base64，sha1、md5 为 hex)
+type EncodedPassword struct {
+	Algorithm   string // 算法，如 pbkdf2_sha256、argon2、bcrypt_sha256、bcrypt、scrypt、sha1、md5、unsalted_md5
+	Iterations  int    // pbkdf2 的迭代次数，argon2 的 time，bcrypt 的 cost，scrypt 的 n；其他算法为0
+	Memory      int    // argon2 的内存(KiB)
+	BlockSize   int    // scrypt 的 r
+	Parallelism int    // argon2、scrypt 的 p
+	Variant     string // argon2 的类型(如 argon2id)，bcrypt 的版本(如 2b)
+	Version     int    // argon2 的版本
+	Salt        string // 盐，unsalted 算法为空
+	Hash        string
+}
+
+// ParseEncoded 解析 encoded，格式不正确时返回 ErrInvalidEncoded，不支持的算法返回 ErrUnknownHasher。
+// 支持 django 的 pbkdf2_sha256、argon2、bcrypt_sha256、bcrypt、scrypt，以及老系统导入的 sha1、md5、unsalted_sha1、unsalted_md5
+func ParseEncoded(encoded string) (*EncodedPassword, error) {
+	algorithm := identifyAlgorithm(encoded)
+	if algorithm == "" {
+		return nil, fmt.Errorf("%w: missing algorithm", ErrInvalidEncoded)
+	}
+	switch algorithm {
+	case "pbkdf2_sha256":
+		return parsePBKDF2(algorithm, encoded)
+	case "argon2":
+		return parseArgon2Encoded(encoded)
+	case "bcrypt_sha256", "bcrypt":
+		return parseBcrypt(algorithm, encoded)
+	case "scrypt":
+		return parseScrypt(encoded)
+	case "sha1", "md5":
+		return parseSaltedDigest(algorithm, encoded)
+	case "unsalted_sha1", "unsalted_md5":
+		return parseUnsaltedDigest(algorithm, encoded)
+	}
+	return nil, ErrUnknownHasher
+}
+
+// identifyAlgorithm encoded 使用的算法，与 django 的 identify_hasher 一致：
+// 32位且不含 $ 或 md5$$ 开头的37位为 unsalted_md5，sha1$$ 开头的46位为 unsalted_sha1，其他取第一个 $ 之前的部分
+func identifyAlgorithm(encoded string) string {
+	if (len(encoded) == 32 && !strings.Contains(encoded, "$")) || (len(encoded) == 37 && strings.HasPrefix(encoded, "md5$$")) {
+		return "unsalted_md5"
+	}
+	if len(encoded) == 46 && strings.HasPrefix(encoded, "sha1$$") {
+		return "unsalted_sha1"
+	}
+	algorithm, _, ok := strings.Cut(encoded, "$")
+	if !ok {
+		return ""
+	}
+	return algorithm
+}
+
+// parsePBKDF2 <algorithm>$<迭代次数>$<盐>$<base64哈希>
+func parsePBKDF2(algorithm, encoded string) (*EncodedPassword, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 {
+		return nil, invalidEncoded(algorithm, "expected 4 parts")
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return nil, invalidEncoded(algorithm, "invalid iterations")
+	}
+	if parts[2] == "" {
+		return nil, invalidEncoded(algorithm, "missing salt")
+	}
+	if !isBase64(parts[3], base64.StdEncoding) {
+		return nil, invalidEncoded(algorithm, "invalid hash")
+	}
+	return &EncodedPassword{Algorithm: algorithm, Iterations: iterations, Salt: parts[2], Hash: parts[3]}, nil
+}
+
+// parseArgon2Encoded argon2$argon2id$v=19$m=...,t=...,p=...$<盐>$<哈希>
+func parseArgon2Encoded(encoded string) (*EncodedPassword, error) {
+	params, err := parseArgon2(strings.TrimPrefix(encoded, "argon2"))
+	if err != nil {
+		return nil, invalidEncoded("argon2", "invalid parameters")
+	}
+	parts := strings.Split(encoded, "$")
+	return &EncodedPassword{
+		Algorithm:   "argon2",
+		Iterations:  int(params.time),
+		Memory:      int(params.memory),
+		Parallelism: int(params.parallelism),
+		Variant:     params.variant,
+		Version:     params.version,
+		Salt:        parts[4],
+		Hash:        parts[5],
+	}, nil
+}
+
+// parseBcrypt <algorithm>$$2b$<cost>$<22位盐><31位哈希>
+func parseBcrypt(algorithm, encoded string) (*EncodedPassword, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 5 || parts[1] != "" {
+		return nil, invalidEncoded(algorithm, "expected bcrypt format")
+	}
+	switch parts[2] {
+	case "2a", "2b", "2y":
+	default:
+		return nil, invalidEncoded(algorithm, "unknown bcrypt version")
+	}
+	cost, err := strconv.Atoi(parts[3])
+	if err != nil || cost <= 0 {
+		return nil, invalidEncoded(algorithm, "invalid cost")
+	}
+	if len(parts[4]) != 53 {
+		return nil, invalidEncoded(algorithm, "invalid salt and hash")
+	}
+	return &EncodedPassword{
+		Algorithm:  algorithm,
+		Iterations: cost,
+		Variant:    parts[2],
+		Salt:       parts[4][:22],
+		Hash:       parts[4][22:],
+	}, nil
+}
+
+// parseScrypt scrypt$<n>$<盐>$<r>$<p>$<base64哈希>
+func parseScrypt(encoded string) (*EncodedPassword, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return nil, invalidEncoded("scrypt", "expected 6 parts")
+	}
+	n, errN := strconv.Atoi(parts[1])
+	r, errR := strconv.Atoi(parts[3])
+	p, errP := strconv.Atoi(parts[4])
+	if errN != nil || errR != nil || errP != nil || n <= 1 || r <= 0 || p <= 0 {
+		return nil, invalidEncoded("scrypt", "invalid parameters")
+	}
+	if parts[2] == "" {
+		return nil, invalidEncoded("scrypt", "missing salt")
+	}
+	if !isBase64(parts[5], base64.StdEncoding) {
+		return nil, invalidEncoded("scrypt", "invalid hash")
+	}
+	return &EncodedPassword{Algorithm: "scrypt", Iterations: n, BlockSize: r, Parallelism: p, Salt: parts[2], Hash: parts[5]}, nil
+}
+
+// parseSaltedDigest sha1$<盐>$<hex哈希>、md5$<盐>$<hex哈希>
+func parseSaltedDigest(algorithm, encoded string) (*EncodedPassword, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 3 {
+		return nil, invalidEncoded(algorithm, "expected 3 parts")
+	}
+	if parts[1] == "" {
+		return nil, invalidEncoded(algorithm, "missing salt")
+	}
+	if !isHex(parts[2], digestSize(algorithm)) {
+		return nil, invalidEncoded(algorithm, "invalid hash")
+	}
+	return &EncodedPassword{Algorithm: algorithm, Salt: parts[1], Hash: parts[2]}, nil
+}
+
+// parseUnsaltedDigest unsalted_sha1 为 sha1$$<hex哈希>，unsalted_md5 为 <hex哈希> 或 md5$$<hex哈希>
+func parseUnsaltedDigest(algorithm, encoded string) (*EncodedPassword, error) {
+	hash := encoded
+	if i := strings.LastIndex(encoded, "$"); i >= 0 {
+		hash = encoded[i+1:]
+	}
+	if !isHex(hash, digestSize(algorithm)) {
+		return nil, invalidEncoded(algorithm, "invalid hash")
+	}
+	return &EncodedPassword{Algorithm: algorithm, Hash: hash}, nil
+}
+
+func invalidEncoded(algorithm, reason string) error {
+	return fmt.Errorf("%w: %s: %s", ErrInvalidEncoded, algorithm, reason)
+}
+
+func isBase64(s string, encoding *base64.Encoding) bool {
+	b, err := encoding.DecodeString(s)
+	return err == nil && len(b) > 0
+}
+
+// isHex 是否是size字节的hex
+func isHex(s string, size int) bool {
+	if len(s) != size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
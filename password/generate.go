@@ -0,0 +1,151 @@
+package password
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+)
+
+// 生成密码的默认设置
+const (
+	DEFAULT_GENERATED_LENGTH  = 16
+	DEFAULT_GENERATE_ATTEMPTS = 100
+)
+
+// 生成密码使用的字符类型
+const (
+	UPPER_CHARS  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	LOWER_CHARS  = "abcdefghijklmnopqrstuvwxyz"
+	DIGIT_CHARS  = "0123456789"
+	SYMBOL_CHARS = "!#$%&()*+-.:;<=>?@[]^_{}~"
+)
+
+var (
+	ErrGenerateFailed  = errors.New("could not generate a password that passes the validators")
+	ErrLengthTooShort  = errors.New("password length is shorter than the number of character classes")
+	ErrEmptyCharacters = errors.New("password character class is empty")
+)
+
+// DefaultCharacterClasses 大写、小写、数字、符号
+var DefaultCharacterClasses = []string{UPPER_CHARS, LOWER_CHARS, DIGIT_CHARS, SYMBOL_CHARS}
+
+// Generator 使用 crypto/rand 生成随机密码，如临时密码、初始密码。
+// 每种字符类型至少包含一个，生成的密码通过 Validators 的校验后才返回
+type Generator struct {
+	Length      int        // 密码长度，0为默认值DEFAULT_GENERATED_LENGTH，小于 Validators 中 MinimumLengthValidator 的要求时使用其要求的长度
+	Classes     []string   // 字符类型，每种至少包含一个，为空时使用DefaultCharacterClasses
+	Validators  Validators // 为nil时使用DefaultValidators
+	MaxAttempts int        // 校验不通过时最多尝试的次数，0为默认值DEFAULT_GENERATE_ATTEMPTS
+}
+
+// GeneratePassword 使用默认设置生成一个通过 DefaultValidators 校验的随机密码
+func GeneratePassword(user UserAttributes) (string, error) {
+	return (&Generator{}).Generate(user)
+}
+
+// Generate 生成一个随机密码，user 用于 UserAttributeSimilarityValidator，可为nil。
+// 校验器返回 *ValidationError 以外的错误(如 BreachedPasswordValidator 查询失败)时直接返回该错误
+func (g *Generator) Generate(user UserAttributes) (string, error) {
+	validators := g.Validators
+	if validators == nil {
+		validators = DefaultValidators
+	}
+	classes := g.Classes
+	if len(classes) == 0 {
+		classes = DefaultCharacterClasses
+	}
+	length := g.Length
+	if length == 0 {
+		length = DEFAULT_GENERATED_LENGTH
+	}
+	length = max(length, minLength(validators))
+	if length < len(classes) {
+		return "", ErrLengthTooShort
+	}
+	all := ""
+	for _, chars := range classes {
+		if chars == "" {
+			return "", ErrEmptyCharacters
+		}
+		all += chars
+	}
+	attempts := g.MaxAttempts
+	if attempts == 0 {
+		attempts = DEFAULT_GENERATE_ATTEMPTS
+	}
+	for i := 0; i < attempts; i++ {
+		password, err := generate(length, classes, all)
+		if err != nil {
+			return "", err
+		}
+		err = validators.Validate(password, user)
+		switch err.(type) {
+		case nil:
+			return password, nil
+		case *ValidationError, ValidationErrors:
+			continue
+		default:
+			return "", err
+		}
+	}
+	return "", ErrGenerateFailed
+}
+
+// generate 每种字符类型各取一个，其余从所有字符中随机选取，最后打乱顺序
+func generate(length int, classes []string, all string) (string, error) {
+	b := make([]byte, 0, length)
+	for _, chars := range classes {
+		c, err := randomChar(chars)
+		if err != nil {
+			return "", err
+		}
+		b = append(b, c)
+	}
+	for len(b) < length {
+		c, err := randomChar(all)
+		if err != nil {
+			return "", err
+		}
+		b = append(b, c)
+	}
+	for i := len(b) - 1; i > 0; i-- {
+		j, err := randomInt(i + 1)
+		if err != nil {
+			return "", err
+		}
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b), nil
+}
+
+// minLength Validators 中 MinimumLengthValidator 要求的最短长度
+func minLength(validators Validators) int {
+	length := 0
+	for _, validator := range validators {
+		if v, ok := validator.(*MinimumLengthValidator); ok {
+			minLength := v.MinLength
+			if minLength == 0 {
+				minLength = DEFAULT_MIN_LENGTH
+			}
+			length = max(length, minLength)
+		}
+	}
+	return length
+}
+
+func randomChar(chars string) (byte, error) {
+	i, err := randomInt(len(chars))
+	if err != nil {
+		return 0, err
+	}
+	return chars[i], nil
+}
+
+// randomInt 使用 crypto/rand 生成 [0, n) 的随机数
+func randomInt(n int) (int, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(i.Int64()), nil
+}
@@ -0,0 +1,168 @@
+package password
+
+import (
+	"errors"
+	"math"
+)
+
+// SALT_LENGTH 新生成的盐的长度，与 django 的 BasePasswordHasher.salt() 一致
+const SALT_LENGTH = 22
+
+// saltChars 盐使用的字符
+const saltChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+var (
+	ErrUnknownHasher  = errors.New("unknown password hasher")
+	ErrInvalidEncoded = errors.New("invalid encoded password")
+)
+
+// Hasher 密码哈希算法，对应 django 的 BasePasswordHasher
+type Hasher interface {
+	// Algorithm encoded 的前缀，如 pbkdf2_sha256、argon2、bcrypt_sha256、scrypt
+	Algorithm() string
+	// Encode 使用随机的盐将明文密码转为 encoded
+	Encode(password string) (string, error)
+	// Verify 判断明文密码是否与 encoded 一致
+	Verify(password, encoded string) bool
+	// MustUpdate encoded 的参数(迭代次数、cost等)或盐的强度是否低于当前设置，需要重新生成，对应 django 的 must_update
+	MustUpdate(encoded string) bool
+}
+
+// RuntimeHardener 可选接口，对应 django 的 harden_runtime：
+// 密码错误且 encoded 需要更新时，补足与当前参数相差的计算量，避免从耗时上判断出该用户的密码较旧
+type RuntimeHardener interface {
+	HardenRuntime(password, encoded string)
+}
+
+// HasherRegistry 一组密码哈希算法，对应 django 的 PASSWORD_HASHERS 设置：
+// 第一个为首选算法，用于生成新密码；其余的只用于验证老密码，由 encoded 的前缀选择
+type HasherRegistry struct {
+	hashers []Hasher
+}
+
+// NewHasherRegistry 新建一个HasherRegistry，第一个hasher为首选算法
+func NewHasherRegistry(hashers ...Hasher) *HasherRegistry {
+	return &HasherRegistry{hashers: hashers}
+}
+
+// DefaultHashers IsSame、MakePassword 使用的算法，与 django 默认的 PASSWORD_HASHERS 相同，首选 pbkdf2_sha256。
+// 如需首选 argon2，可替换为 NewHasherRegistry(&Argon2Hasher{}, &PBKDF2SHA256Hasher{}, ...)
+var DefaultHashers = NewHasherRegistry(
+	&PBKDF2SHA256Hasher{},
+	&Argon2Hasher{},
+	&BCryptSHA256Hasher{},
+	&BCryptHasher{},
+	&ScryptHasher{},
+)
+
+// Preferred 首选的算法
+func (r *HasherRegistry) Preferred() Hasher {
+	if len(r.hashers) == 0 {
+		return nil
+	}
+	return r.hashers[0]
+}
+
+// Get 根据算法名称获取hasher，对应 django 的 get_hasher
+func (r *HasherRegistry) Get(algorithm string) (Hasher, error) {
+	for _, hasher := range r.hashers {
+		if hasher.Algorithm() == algorithm {
+			return hasher, nil
+		}
+	}
+	return nil, ErrUnknownHasher
+}
+
+// Identify 根据 encoded 的前缀获取hasher，对应 django 的 identify_hasher，unsalted_md5 等没有前缀的格式见 identifyAlgorithm
+func (r *HasherRegistry) Identify(encoded string) (Hasher, error) {
+	algorithm := identifyAlgorithm(encoded)
+	if algorithm == "" {
+		return nil, ErrUnknownHasher
+	}
+	return r.Get(algorithm)
+}
+
+// Hashers 所有的hasher，第一个为首选算法。返回的是副本，可用于组合新的HasherRegistry
+func (r *HasherRegistry) Hashers() []Hasher {
+	return append([]Hasher(nil), r.hashers...)
+}
+
+// Make 使用首选算法将明文密码转为 encoded，对应 django 的 make_password
+func (r *HasherRegistry) Make(password string) (string, error) {
+	hasher := r.Preferred()
+	if hasher == nil {
+		return "", ErrUnknownHasher
+	}
+	return hasher.Encode(password)
+}
+
+// Check 判断明文密码是否与 encoded 一致，由 encoded 的前缀选择算法，对应 django 的 check_password
+func (r *HasherRegistry) Check(password, encoded string) bool {
+	hasher, err := r.Identify(encoded)
+	if err != nil {
+		return false
+	}
+	return hasher.Verify(password, encoded)
+}
+
+// Verify 判断明文密码是否与 encoded 一致，对应 django 带 setter 的 check_password：
+// 密码正确，且 encoded 不是首选算法或参数已过时时，needsRehash 为 true，newEncoded 为用首选算法重新生成的密码，
+// 调用者应将其保存，以便提高迭代次数或更换算法后老用户的密码在登录时逐步升级。生成失败时 newEncoded 为空
+func (r *HasherRegistry) Verify(password, encoded string) (ok, needsRehash bool, newEncoded string) {
+	preferred := r.Preferred()
+	hasher, err := r.Identify(encoded)
+	if err != nil || preferred == nil {
+		return false, false, ""
+	}
+	hasherChanged := hasher.Algorithm() != preferred.Algorithm()
+	mustUpdate := hasherChanged || preferred.MustUpdate(encoded)
+	ok = hasher.Verify(password, encoded)
+	if !ok {
+		// 算法没变时才补足耗时，与 django 一致
+		if !hasherChanged && mustUpdate {
+			if hardener, isHardener := hasher.(RuntimeHardener); isHardener {
+				hardener.HardenRuntime(password, encoded)
+			}
+		}
+		return false, false, ""
+	}
+	if !mustUpdate {
+		return true, false, ""
+	}
+	newEncoded, err = preferred.Encode(password)
+	if err != nil {
+		return true, true, ""
+	}
+	return true, true, newEncoded
+}
+
+// Verify 使用 DefaultHashers 验证密码，并在需要时返回用首选算法重新生成的密码，见 HasherRegistry.Verify
+func Verify(password, encoded string) (ok, needsRehash bool, newEncoded string) {
+	return DefaultHashers.Verify(password, encoded)
+}
+
+// MakePassword 使用 DefaultHashers 的首选算法将明文密码转为 encoded
+func MakePassword(password string) (string, error) {
+	return DefaultHashers.Make(password)
+}
+
+// SALT_ENTROPY 盐至少需要的熵(bit)，与 django 的 BasePasswordHasher.salt_entropy 一致
+const SALT_ENTROPY = 128
+
+// mustUpdateSalt 盐的熵是否不足，对应 django 的 must_update_salt。Encrypt 默认的12位盐只有约71bit
+func mustUpdateSalt(salt string) bool {
+	return float64(len(salt))*math.Log2(float64(len(saltChars))) < SALT_ENTROPY
+}
+
+// newSalt 使用 crypto/rand 生成随机的盐(字母和数字)
+func newSalt() (string, error) {
+	b := make([]byte, SALT_LENGTH)
+	for i := range b {
+		c, err := randomChar(saltChars)
+		if err != nil {
+			return "", err
+		}
+		b[i] = c
+	}
+	return string(b), nil
+}
@@ -0,0 +1,144 @@
+package password
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/hex"
+	"hash"
+)
+
+// LegacyHashers django 早期版本的 sha1、md5 等算法，不安全，只用于导入老系统的用户表。
+// 加入 HasherRegistry 的非首选位置后，老用户登录时 HasherRegistry.Verify 会返回用首选算法重新生成的密码：
+//
+//	NewHasherRegistry(append(DefaultHashers.Hashers(), LegacyHashers...)...)
+var LegacyHashers = []Hasher{
+	&SHA1Hasher{},
+	&MD5Hasher{},
+	&UnsaltedSHA1Hasher{},
+	&UnsaltedMD5Hasher{},
+}
+
+// SHA1Hasher 与 django 的 SHA1PasswordHasher 格式兼容：sha1$<盐>$<hex(sha1(盐+密码))>
+type SHA1Hasher struct{}
+
+func (h *SHA1Hasher) Algorithm() string {
+	return "sha1"
+}
+
+func (h *SHA1Hasher) Encode(password string) (string, error) {
+	return encodeSaltedDigest(h.Algorithm(), sha1.New, password)
+}
+
+func (h *SHA1Hasher) Verify(password, encoded string) bool {
+	return verifyDigest(h.Algorithm(), sha1.New, password, encoded)
+}
+
+// MustUpdate 盐的熵不足时需要更新
+func (h *SHA1Hasher) MustUpdate(encoded string) bool {
+	return mustUpdateDigestSalt(h.Algorithm(), encoded)
+}
+
+// MD5Hasher 与 django 的 MD5PasswordHasher 格式兼容：md5$<盐>$<hex(md5(盐+密码))>
+type MD5Hasher struct{}
+
+func (h *MD5Hasher) Algorithm() string {
+	return "md5"
+}
+
+func (h *MD5Hasher) Encode(password string) (string, error) {
+	return encodeSaltedDigest(h.Algorithm(), md5.New, password)
+}
+
+func (h *MD5Hasher) Verify(password, encoded string) bool {
+	return verifyDigest(h.Algorithm(), md5.New, password, encoded)
+}
+
+// MustUpdate 盐的熵不足时需要更新
+func (h *MD5Hasher) MustUpdate(encoded string) bool {
+	return mustUpdateDigestSalt(h.Algorithm(), encoded)
+}
+
+// UnsaltedSHA1Hasher 与 django 的 UnsaltedSHA1PasswordHasher 格式兼容：sha1$$<hex(sha1(密码))>
+type UnsaltedSHA1Hasher struct{}
+
+func (h *UnsaltedSHA1Hasher) Algorithm() string {
+	return "unsalted_sha1"
+}
+
+func (h *UnsaltedSHA1Hasher) Encode(password string) (string, error) {
+	return "sha1$$" + digestHex(sha1.New, "", password), nil
+}
+
+func (h *UnsaltedSHA1Hasher) Verify(password, encoded string) bool {
+	return verifyDigest(h.Algorithm(), sha1.New, password, encoded)
+}
+
+// MustUpdate 没有可以调整的参数，由 HasherRegistry 更换为首选算法
+func (h *UnsaltedSHA1Hasher) MustUpdate(encoded string) bool {
+	return false
+}
+
+// UnsaltedMD5Hasher 与 django 的 UnsaltedMD5PasswordHasher 格式兼容：<hex(md5(密码))>，也支持 md5$$<hex> 的写法
+type UnsaltedMD5Hasher struct{}
+
+func (h *UnsaltedMD5Hasher) Algorithm() string {
+	return "unsalted_md5"
+}
+
+func (h *UnsaltedMD5Hasher) Encode(password string) (string, error) {
+	return digestHex(md5.New, "", password), nil
+}
+
+func (h *UnsaltedMD5Hasher) Verify(password, encoded string) bool {
+	return verifyDigest(h.Algorithm(), md5.New, password, encoded)
+}
+
+// MustUpdate 没有可以调整的参数，由 HasherRegistry 更换为首选算法
+func (h *UnsaltedMD5Hasher) MustUpdate(encoded string) bool {
+	return false
+}
+
+func encodeSaltedDigest(algorithm string, newHash func() hash.Hash, password string) (string, error) {
+	salt, err := newSalt()
+	if err != nil {
+		return "", err
+	}
+	return algorithm + "$" + salt + "$" + digestHex(newHash, salt, password), nil
+}
+
+// verifyDigest 解析 encoded 并以常数时间比较哈希
+func verifyDigest(algorithm string, newHash func() hash.Hash, password, encoded string) bool {
+	decoded, err := ParseEncoded(encoded)
+	if err != nil || decoded.Algorithm != algorithm {
+		return false
+	}
+	expected := digestHex(newHash, decoded.Salt, password)
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(decoded.Hash)) == 1
+}
+
+func mustUpdateDigestSalt(algorithm, encoded string) bool {
+	decoded, err := ParseEncoded(encoded)
+	if err != nil || decoded.Algorithm != algorithm {
+		return false
+	}
+	return mustUpdateSalt(decoded.Salt)
+}
+
+// digestHex hex(hash(盐+密码))，hex 为小写，与 python 的 hexdigest 一致
+func digestHex(newHash func() hash.Hash, salt, password string) string {
+	h := newHash()
+	h.Write([]byte(salt + password))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// digestSize sha1、md5 类算法的哈希字节数
+func digestSize(algorithm string) int {
+	switch algorithm {
+	case "sha1", "unsalted_sha1":
+		return sha1.Size
+	case "md5", "unsalted_md5":
+		return md5.Size
+	}
+	return 0
+}
@@ -1,10 +1,15 @@
+// Package password 与Django兼容的密码哈希：pbkdf2_sha256、argon2、bcrypt、scrypt。
+// 密码校验器移植自Django的AUTH_PASSWORD_VALIDATORS。
+// BreachedPasswordValidator以k-anonymity查询或离线列表检查泄露的密码。
+// 导入老数据时使用的sha1、md5哈希见LegacyHashers。
+// 另有与Django兼容的密码重置token和随机密码生成。
 package password
 
 import (
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
 	"strconv"
-	"strings"
 
 	"github.com/adamesong/go-util/random"
 	"golang.org/x/crypto/pbkdf2"
@@ -27,7 +32,7 @@ func Encrypt(password, saltString string, iterations int) string {
 		salt = []byte(saltString)
 	}
 	if iterations == 0 {
-		iterations = 120000 // 加密算法的迭代次数，120000 次
+		iterations = DEFAULT_PBKDF2_ITERATIONS // 加密算法的迭代次数，120000 次
 	}
 	digest := sha256.New // digest 算法，使用 sha256
 
@@ -42,40 +47,67 @@ func Encrypt(password, saltString string, iterations int) string {
 }
 
 // IsSamePassword用来判断password字符串encode后是否与encoded一致
-// pbkdf2_sha256$120000$ONRhfKsUOHoF$xHEtXKw7u4F5hhdEj8sMUwHOcP06KBFliFnYzF7qYnw= 包括了4个部分，分别是：
-// pbkdf2_sha256 100000 M1BIGL7NBnF1 gACxYtYQItPQ73FiWKYnYbCDdJeV2zlhobVcdkTd/Lg=
+// 由encoded的前缀选择DefaultHashers中的算法，支持pbkdf2_sha256、argon2、bcrypt_sha256、bcrypt、scrypt。
+// encoded 为空或格式不正确时返回false
 func IsSame(password, encoded string) bool {
-	encodedArray := strings.Split(encoded, "$")
-	iterations, _ := strconv.Atoi(encodedArray[1])
-	salt := encodedArray[2]
-	toBeVerified := Encrypt(password, salt, iterations)
-	return toBeVerified == encoded
+	return DefaultHashers.Check(password, encoded)
+}
+
+// DEFAULT_PBKDF2_ITERATIONS Encrypt 默认的迭代次数
+const DEFAULT_PBKDF2_ITERATIONS = 120000
+
+// PBKDF2SHA256Hasher django 默认的 pbkdf2_sha256，即 Encrypt 生成的格式
+type PBKDF2SHA256Hasher struct {
+	Iterations int // 0为默认值DEFAULT_PBKDF2_ITERATIONS
+}
+
+func (h *PBKDF2SHA256Hasher) Algorithm() string {
+	return "pbkdf2_sha256"
+}
+
+func (h *PBKDF2SHA256Hasher) Encode(password string) (string, error) {
+	salt, err := newSalt()
+	if err != nil {
+		return "", err
+	}
+	return Encrypt(password, salt, h.Iterations), nil
+}
+
+// Verify pbkdf2_sha256$120000$ONRhfKsUOHoF$xHEtXKw7u4F5hhdEj8sMUwHOcP06KBFliFnYzF7qYnw= 包括了4个部分，分别是：
+// pbkdf2_sha256 100000 M1BIGL7NBnF1 gACxYtYQItPQ73FiWKYnYbCDdJeV2zlhobVcdkTd/Lg=
+// encoded 格式不正确时返回false，使用常数时间比较，避免从耗时上猜出哈希
+func (h *PBKDF2SHA256Hasher) Verify(password, encoded string) bool {
+	decoded, err := ParseEncoded(encoded)
+	if err != nil || decoded.Algorithm != h.Algorithm() {
+		return false
+	}
+	toBeVerified := Encrypt(password, decoded.Salt, decoded.Iterations)
+	return subtle.ConstantTimeCompare([]byte(toBeVerified), []byte(encoded)) == 1
+}
+
+// MustUpdate 迭代次数与当前设置不同，或盐的熵不足时需要更新
+func (h *PBKDF2SHA256Hasher) MustUpdate(encoded string) bool {
+	decoded, err := ParseEncoded(encoded)
+	if err != nil || decoded.Algorithm != h.Algorithm() {
+		return false
+	}
+	return decoded.Iterations != h.iterations() || mustUpdateSalt(decoded.Salt)
 }
 
-// todo 验证password的设置规则，如
-// if len(password) < self.min_length:
-// DEFAULT_USER_ATTRIBUTES = ('username', 'first_name', 'last_name', 'email')
-//
-//    def __init__(self, user_attributes=DEFAULT_USER_ATTRIBUTES, max_similarity=0.7):
-//        self.user_attributes = user_attributes
-//        self.max_similarity = max_similarity
-//
-//    def validate(self, password, user=None):
-//        if not user:
-//            return
-//
-//        for attribute_name in self.user_attributes:
-//            value = getattr(user, attribute_name, None)
-//            if not value or not isinstance(value, str):
-//                continue
-//            value_parts = re.split(r'\W+', value) + [value]
-//            for value_part in value_parts:
-//                if SequenceMatcher(a=password.lower(), b=value_part.lower()).quick_ratio() >= self.max_similarity:
-//                    try:
-//                        verbose_name = str(user._meta.get_field(attribute_name).verbose_name)
-//                    except FieldDoesNotExist:
-//                        verbose_name = attribute_name
-//                    raise ValidationError(
-//                        _("The password is too similar to the %(verbose_name)s."),
-//                        code='password_too_similar',
-//                        params={'verbose_name': verbose_name},
+// HardenRuntime 补足老密码比当前设置少的迭代次数
+func (h *PBKDF2SHA256Hasher) HardenRuntime(password, encoded string) {
+	decoded, err := ParseEncoded(encoded)
+	if err != nil || decoded.Algorithm != h.Algorithm() {
+		return
+	}
+	if extra := h.iterations() - decoded.Iterations; extra > 0 {
+		Encrypt(password, decoded.Salt, extra)
+	}
+}
+
+func (h *PBKDF2SHA256Hasher) iterations() int {
+	if h.Iterations == 0 {
+		return DEFAULT_PBKDF2_ITERATIONS
+	}
+	return h.Iterations
+}
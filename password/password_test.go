@@ -0,0 +1,224 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 使用低参数加快测试
+var testHashers = []Hasher{
+	&PBKDF2SHA256Hasher{Iterations: 1000},
+	&Argon2Hasher{Time: 1, Memory: 1024, Parallelism: 1},
+	&BCryptSHA256Hasher{Cost: 4},
+	&BCryptHasher{Cost: 4},
+	&ScryptHasher{N: 1024},
+}
+
+func TestHashers(t *testing.T) {
+	for _, hasher := range testHashers {
+		t.Run(hasher.Algorithm(), func(t *testing.T) {
+			assertion := assert.New(t)
+			encoded, err := hasher.Encode("correct horse")
+			require.NoError(t, err)
+			assertion.True(strings.HasPrefix(encoded, hasher.Algorithm()+"$"))
+			assertion.True(hasher.Verify("correct horse", encoded))
+			assertion.False(hasher.Verify("wrong horse", encoded))
+
+			// 每次使用不同的盐
+			other, err := hasher.Encode("correct horse")
+			require.NoError(t, err)
+			assertion.NotEqual(encoded, other)
+		})
+	}
+}
+
+func TestDjangoEncoded(t *testing.T) {
+	assertion := assert.New(t)
+	// 由 python hashlib 按 django 的格式生成
+	assertion.True(IsSame("correct horse", "pbkdf2_sha256$120000$Qx1pZ3m9XbT0aWcL8yNe2r$3zLu7PorWnALhp8mSTZWlqnEH3xUgJE9AyGxNDbgno8="))
+	assertion.True(IsSame("correct horse", "scrypt$16384$Qx1pZ3m9XbT0aWcL8yNe2r$8$1$Cx+OdX54TcPsTPjfNekWIz++xG4m7PpVpowL/kuEdzQiENf/xn1S+tGhLgN0i9mmHhtr8j+NfHOuW6B+/6utUQ=="))
+	assertion.False(IsSame("wrong horse", "scrypt$16384$Qx1pZ3m9XbT0aWcL8yNe2r$8$1$Cx+OdX54TcPsTPjfNekWIz++xG4m7PpVpowL/kuEdzQiENf/xn1S+tGhLgN0i9mmHhtr8j+NfHOuW6B+/6utUQ=="))
+
+	// django(python bcrypt)生成的是$2b$
+	encoded, err := (&BCryptSHA256Hasher{Cost: 4}).Encode("correct horse")
+	require.NoError(t, err)
+	assertion.True(IsSame("correct horse", strings.Replace(encoded, "$2a$", "$2b$", 1)))
+}
+
+func TestHasherRegistry(t *testing.T) {
+	assertion := assert.New(t)
+	registry := NewHasherRegistry(testHashers[1], testHashers[0])
+	encoded, err := registry.Make("correct horse")
+	require.NoError(t, err)
+	assertion.True(strings.HasPrefix(encoded, "argon2$argon2id$v=19$m=1024,t=1,p=1$"))
+	assertion.True(registry.Check("correct horse", encoded))
+
+	// 非首选的算法仍可验证
+	legacy := Encrypt("correct horse", "", 1000)
+	assertion.True(registry.Check("correct horse", legacy))
+
+	// 未注册的算法
+	bcryptEncoded, err := testHashers[2].Encode("correct horse")
+	require.NoError(t, err)
+	assertion.False(registry.Check("correct horse", bcryptEncoded))
+	_, err = registry.Identify(bcryptEncoded)
+	assertion.Equal(ErrUnknownHasher, err)
+	_, err = registry.Identify("no-prefix")
+	assertion.Equal(ErrUnknownHasher, err)
+}
+
+func TestVerifyRehash(t *testing.T) {
+	assertion := assert.New(t)
+	pbkdf2 := &PBKDF2SHA256Hasher{Iterations: 1000}
+	registry := NewHasherRegistry(pbkdf2, testHashers[1], testHashers[2])
+
+	// 当前设置生成的密码不需要更新
+	current, err := registry.Make("correct horse")
+	require.NoError(t, err)
+	ok, needsRehash, newEncoded := registry.Verify("correct horse", current)
+	assertion.True(ok)
+	assertion.False(needsRehash)
+	assertion.Empty(newEncoded)
+
+	// 迭代次数不同
+	fewer := Encrypt("correct horse", "Qx1pZ3m9XbT0aWcL8yNe2r", 500)
+	ok, needsRehash, newEncoded = registry.Verify("correct horse", fewer)
+	assertion.True(ok)
+	assertion.True(needsRehash)
+	assertion.True(strings.HasPrefix(newEncoded, "pbkdf2_sha256$1000$"))
+	assertion.True(registry.Check("correct horse", newEncoded))
+
+	// 盐太短(Encrypt默认的12位)
+	assertion.True(pbkdf2.MustUpdate(Encrypt("correct horse", "", 1000)))
+
+	// 非首选算法
+	argon, err := testHashers[1].Encode("correct horse")
+	require.NoError(t, err)
+	ok, needsRehash, newEncoded = registry.Verify("correct horse", argon)
+	assertion.True(ok)
+	assertion.True(needsRehash)
+	assertion.True(strings.HasPrefix(newEncoded, "pbkdf2_sha256$"))
+
+	// 密码错误时不返回新密码
+	ok, needsRehash, newEncoded = registry.Verify("wrong horse", fewer)
+	assertion.False(ok)
+	assertion.False(needsRehash)
+	assertion.Empty(newEncoded)
+
+	ok, _, _ = registry.Verify("correct horse", "unknown$encoded")
+	assertion.False(ok)
+}
+
+func TestHasherMustUpdate(t *testing.T) {
+	assertion := assert.New(t)
+	for _, hasher := range testHashers {
+		encoded, err := hasher.Encode("correct horse")
+		require.NoError(t, err)
+		assertion.False(hasher.MustUpdate(encoded), hasher.Algorithm())
+	}
+
+	weakArgon, err := (&Argon2Hasher{Time: 1, Memory: 1024, Parallelism: 1}).Encode("correct horse")
+	require.NoError(t, err)
+	assertion.True((&Argon2Hasher{Time: 2, Memory: 1024, Parallelism: 1}).MustUpdate(weakArgon))
+
+	weakBcrypt, err := (&BCryptSHA256Hasher{Cost: 4}).Encode("correct horse")
+	require.NoError(t, err)
+	assertion.True((&BCryptSHA256Hasher{Cost: 5}).MustUpdate(weakBcrypt))
+
+	weakScrypt, err := (&ScryptHasher{N: 1024}).Encode("correct horse")
+	require.NoError(t, err)
+	assertion.True((&ScryptHasher{N: 2048}).MustUpdate(weakScrypt))
+}
+
+func TestParseEncoded(t *testing.T) {
+	assertion := assert.New(t)
+	decoded, err := ParseEncoded("pbkdf2_sha256$120000$Qx1pZ3m9XbT0aWcL8yNe2r$3zLu7PorWnALhp8mSTZWlqnEH3xUgJE9AyGxNDbgno8=")
+	require.NoError(t, err)
+	assertion.Equal(&EncodedPassword{Algorithm: "pbkdf2_sha256", Iterations: 120000, Salt: "Qx1pZ3m9XbT0aWcL8yNe2r", Hash: "3zLu7PorWnALhp8mSTZWlqnEH3xUgJE9AyGxNDbgno8="}, decoded)
+
+	decoded, err = ParseEncoded("scrypt$16384$Qx1pZ3m9XbT0aWcL8yNe2r$8$1$Cx+OdX54TcPsTPjfNekWIz++xG4m7PpVpowL/kuEdzQiENf/xn1S+tGhLgN0i9mmHhtr8j+NfHOuW6B+/6utUQ==")
+	require.NoError(t, err)
+	assertion.Equal(16384, decoded.Iterations)
+	assertion.Equal(8, decoded.BlockSize)
+	assertion.Equal(1, decoded.Parallelism)
+
+	encoded, err := testHashers[1].Encode("correct horse")
+	require.NoError(t, err)
+	decoded, err = ParseEncoded(encoded)
+	require.NoError(t, err)
+	assertion.Equal("argon2id", decoded.Variant)
+	assertion.Equal(1024, decoded.Memory)
+	assertion.Len(decoded.Salt, 30) // 22字节的盐，不带padding的base64
+
+	encoded, err = testHashers[2].Encode("correct horse")
+	require.NoError(t, err)
+	decoded, err = ParseEncoded(encoded)
+	require.NoError(t, err)
+	assertion.Equal("bcrypt_sha256", decoded.Algorithm)
+	assertion.Equal(4, decoded.Iterations)
+
+	decoded, err = ParseEncoded("3cb4e732631f47e6eb961f34554b7cde")
+	require.NoError(t, err)
+	assertion.Equal(&EncodedPassword{Algorithm: "unsalted_md5", Hash: "3cb4e732631f47e6eb961f34554b7cde"}, decoded)
+	decoded, err = ParseEncoded("md5$$3cb4e732631f47e6eb961f34554b7cde")
+	require.NoError(t, err)
+	assertion.Equal("unsalted_md5", decoded.Algorithm)
+
+	// 格式不正确的 encoded 不会panic
+	for _, encoded := range []string{
+		"",
+		"pbkdf2_sha256",
+		"pbkdf2_sha256$",
+		"pbkdf2_sha256$abc",
+		"pbkdf2_sha256$abc$salt$hash",
+		"pbkdf2_sha256$120000$$3zLu7PorWnALhp8mSTZWlqnEH3xUgJE9AyGxNDbgno8=",
+		"pbkdf2_sha256$120000$salt$not base64",
+		"argon2$argon2id$v=19",
+		"bcrypt_sha256$$2b$12$short",
+		"scrypt$16384$salt$8$1",
+		"sha1$salt$nothex",
+		"md5$$short",
+	} {
+		_, err := ParseEncoded(encoded)
+		assertion.ErrorIs(err, ErrInvalidEncoded, encoded)
+		assertion.False(IsSame("correct horse", encoded), encoded)
+		ok, _, _ := Verify("correct horse", encoded)
+		assertion.False(ok, encoded)
+	}
+	_, err = ParseEncoded("crypt$salt$hash")
+	assertion.ErrorIs(err, ErrUnknownHasher)
+}
+
+func TestLegacyHashers(t *testing.T) {
+	assertion := assert.New(t)
+	registry := NewHasherRegistry(append([]Hasher{testHashers[0]}, LegacyHashers...)...)
+	// 由 python hashlib 按 django 的格式生成
+	for _, encoded := range []string{
+		"sha1$abcdefghijklmnopqrstuv$4e47d0814a3787b3773b25c8e8d86ce8aa5d9747",
+		"md5$abcdefghijklmnopqrstuv$02144cc061e1c896b28fdbca2ef32806",
+		"sha1$$2f9e53523b62abc141a2b4d6019d23cba835dbd0",
+		"3cb4e732631f47e6eb961f34554b7cde",
+		"md5$$3cb4e732631f47e6eb961f34554b7cde",
+	} {
+		assertion.False(IsSame("correct horse", encoded), "legacy hashers are not in DefaultHashers")
+		assertion.False(registry.Check("wrong horse", encoded), encoded)
+		ok, needsRehash, newEncoded := registry.Verify("correct horse", encoded)
+		assertion.True(ok, encoded)
+		assertion.True(needsRehash, encoded)
+		assertion.True(strings.HasPrefix(newEncoded, "pbkdf2_sha256$1000$"), encoded)
+	}
+
+	for _, hasher := range LegacyHashers {
+		encoded, err := hasher.Encode("correct horse")
+		require.NoError(t, err)
+		identified, err := registry.Identify(encoded)
+		require.NoError(t, err)
+		assertion.Equal(hasher.Algorithm(), identified.Algorithm())
+		assertion.True(hasher.Verify("correct horse", encoded))
+		assertion.False(hasher.MustUpdate(encoded))
+	}
+	assertion.True((&MD5Hasher{}).MustUpdate("md5$abc$02144cc061e1c896b28fdbca2ef32806"))
+}
@@ -0,0 +1,140 @@
+package password
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 与 django 的 PasswordResetTokenGenerator 一致
+const (
+	PASSWORD_RESET_KEY_SALT        = "django.contrib.auth.tokens.PasswordResetTokenGenerator"
+	DEFAULT_PASSWORD_RESET_TIMEOUT = 3 * 24 * time.Hour // django 的 PASSWORD_RESET_TIMEOUT 默认值
+)
+
+// resetEpoch token 中的时间戳为距 2001-01-01 的秒数
+var resetEpoch = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// ResetUser 生成重置密码 token 时用到的用户信息。密码(encoded)或最后登录时间变化后，之前的 token 自动失效
+type ResetUser struct {
+	ID        string    // 用户的主键，如 strconv.FormatInt(user.ID, 10)
+	Password  string    // encoded 密码
+	LastLogin time.Time // 零值表示从未登录
+	Email     string
+}
+
+// PasswordResetTokenGenerator 重置密码的 token，与 django 的 PasswordResetTokenGenerator 兼容(sha256，django 的 TIME_ZONE 为 UTC 时)，
+// 两边使用相同的 SECRET_KEY 时可以互相验证。token 为 <base36时间戳>-<hmac>，不需要存储
+type PasswordResetTokenGenerator struct {
+	Secret          string
+	SecretFallbacks []string      // 更换 Secret 后仍然有效的旧 secret，对应 django 的 SECRET_KEY_FALLBACKS
+	Timeout         time.Duration // token 的有效期，0为默认值DEFAULT_PASSWORD_RESET_TIMEOUT
+	KeySalt         string        // 为空时使用PASSWORD_RESET_KEY_SALT，不同用途(如邮箱验证)的 token 应使用不同的KeySalt
+	Now             func() time.Time
+}
+
+// NewPasswordResetTokenGenerator 新建一个PasswordResetTokenGenerator，secret 对应 django 的 SECRET_KEY
+func NewPasswordResetTokenGenerator(secret string) *PasswordResetTokenGenerator {
+	return &PasswordResetTokenGenerator{Secret: secret}
+}
+
+// MakeToken 为用户生成重置密码的 token，对应 django 的 make_token
+func (g *PasswordResetTokenGenerator) MakeToken(user ResetUser) string {
+	return g.makeTokenWithTimestamp(user, numSeconds(g.now()), g.Secret)
+}
+
+// CheckToken token 是否是该用户的，且没有过期，对应 django 的 check_token
+func (g *PasswordResetTokenGenerator) CheckToken(user ResetUser, token string) bool {
+	if user.ID == "" || token == "" {
+		return false
+	}
+	timestampString, _, ok := strings.Cut(token, "-")
+	// django 的 base36_to_int 最多13位
+	if !ok || len(timestampString) > 13 {
+		return false
+	}
+	timestamp, err := strconv.ParseInt(timestampString, 36, 64)
+	if err != nil {
+		return false
+	}
+	matched := false
+	for _, secret := range append([]string{g.Secret}, g.SecretFallbacks...) {
+		if subtle.ConstantTimeCompare([]byte(g.makeTokenWithTimestamp(user, timestamp, secret)), []byte(token)) == 1 {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return false
+	}
+	return numSeconds(g.now())-timestamp <= int64(g.timeout()/time.Second)
+}
+
+func (g *PasswordResetTokenGenerator) makeTokenWithTimestamp(user ResetUser, timestamp int64, secret string) string {
+	keySalt := g.KeySalt
+	if keySalt == "" {
+		keySalt = PASSWORD_RESET_KEY_SALT
+	}
+	hash := hex.EncodeToString(saltedHMAC(keySalt, resetHashValue(user, timestamp), secret))
+	// django 只取 hex 的偶数位，缩短 token 的长度
+	short := make([]byte, 0, len(hash)/2)
+	for i := 0; i < len(hash); i += 2 {
+		short = append(short, hash[i])
+	}
+	return strconv.FormatInt(timestamp, 36) + "-" + string(short)
+}
+
+func (g *PasswordResetTokenGenerator) now() time.Time {
+	if g.Now != nil {
+		return g.Now()
+	}
+	return time.Now()
+}
+
+func (g *PasswordResetTokenGenerator) timeout() time.Duration {
+	if g.Timeout == 0 {
+		return DEFAULT_PASSWORD_RESET_TIMEOUT
+	}
+	return g.Timeout
+}
+
+// resetHashValue 对应 django 的 _make_hash_value：主键、encoded 密码、最后登录时间(不含微秒)、时间戳、邮箱
+func resetHashValue(user ResetUser, timestamp int64) string {
+	lastLogin := ""
+	if !user.LastLogin.IsZero() {
+		lastLogin = user.LastLogin.UTC().Format(time.DateTime)
+	}
+	return user.ID + user.Password + lastLogin + strconv.FormatInt(timestamp, 10) + user.Email
+}
+
+// saltedHMAC 对应 django.utils.crypto.salted_hmac(algorithm="sha256")：key 为 sha256(keySalt + secret)
+func saltedHMAC(keySalt, value, secret string) []byte {
+	key := sha256.Sum256([]byte(keySalt + secret))
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+// numSeconds 距 2001-01-01 的秒数，对应 django 的 _num_seconds
+func numSeconds(t time.Time) int64 {
+	return int64(t.Sub(resetEpoch) / time.Second)
+}
+
+// EncodeUID 重置密码链接中的用户ID，对应 django 的 urlsafe_base64_encode(force_bytes(pk))
+func EncodeUID(id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(id))
+}
+
+// DecodeUID 解析 EncodeUID 的结果，对应 django 的 urlsafe_base64_decode
+func DecodeUID(uid string) (string, error) {
+	id, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(uid, "="))
+	if err != nil {
+		return "", err
+	}
+	return string(id), nil
+}
@@ -0,0 +1,83 @@
+package password
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordResetTokenGenerator(t *testing.T) {
+	assertion := assert.New(t)
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	generator := NewPasswordResetTokenGenerator("secret-key")
+	generator.Now = func() time.Time { return now }
+	user := ResetUser{
+		ID:        "42",
+		Password:  "pbkdf2_sha256$1000$salt$hash",
+		LastLogin: time.Date(2024, 5, 1, 10, 0, 0, 123456000, time.UTC),
+		Email:     "a@example.com",
+	}
+
+	// 由 python 按 django 的 PasswordResetTokenGenerator 生成
+	token := generator.MakeToken(user)
+	assertion.Equal("c6lftl-c66f90dce55afdf177e0710cd9277df6", token)
+	assertion.True(generator.CheckToken(user, token))
+
+	// 修改密码、登录后失效
+	changed := user
+	changed.Password = "pbkdf2_sha256$1000$salt$other"
+	assertion.False(generator.CheckToken(changed, token))
+	changed = user
+	changed.LastLogin = now
+	assertion.False(generator.CheckToken(changed, token))
+	changed = user
+	changed.ID = "43"
+	assertion.False(generator.CheckToken(changed, token))
+
+	// 过期
+	now = now.Add(DEFAULT_PASSWORD_RESET_TIMEOUT)
+	assertion.True(generator.CheckToken(user, token))
+	now = now.Add(time.Second)
+	assertion.False(generator.CheckToken(user, token))
+	now = now.Add(-DEFAULT_PASSWORD_RESET_TIMEOUT)
+
+	// 更换 secret 后旧的 token 仍可使用 SecretFallbacks 验证
+	rotated := &PasswordResetTokenGenerator{Secret: "new-secret", Now: generator.Now}
+	assertion.False(rotated.CheckToken(user, token))
+	rotated.SecretFallbacks = []string{"secret-key"}
+	assertion.True(rotated.CheckToken(user, token))
+
+	for _, invalid := range []string{"", "c6lftl", "c6lftl-", "zzzzzzzzzzzzzz-c66f90dce55afdf177e0710cd9277df6", "C6LFTL-c66f90dce55afdf177e0710cd9277df6"} {
+		assertion.False(generator.CheckToken(user, invalid), invalid)
+	}
+	assertion.False(generator.CheckToken(ResetUser{}, token))
+
+	uid := EncodeUID("42")
+	assertion.Equal("NDI", uid)
+	id, err := DecodeUID(uid)
+	assertion.NoError(err)
+	assertion.Equal("42", id)
+}
+
+func TestGenerator(t *testing.T) {
+	assertion := assert.New(t)
+	password, err := GeneratePassword(UserAttributes{"username": "john"})
+	assertion.NoError(err)
+	assertion.Len(password, DEFAULT_GENERATED_LENGTH)
+	assertion.NoError((&CharacterClassValidator{RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}).Validate(password, nil))
+
+	// 使用 MinimumLengthValidator 要求的长度
+	generator := &Generator{Length: 8, Validators: Validators{&MinimumLengthValidator{MinLength: 20}}}
+	password, err = generator.Generate(nil)
+	assertion.NoError(err)
+	assertion.Len(password, 20)
+
+	// 只使用数字时无法通过 NumericPasswordValidator
+	generator = &Generator{Classes: []string{DIGIT_CHARS}, Validators: Validators{&NumericPasswordValidator{}}, MaxAttempts: 3}
+	_, err = generator.Generate(nil)
+	assertion.Equal(ErrGenerateFailed, err)
+
+	_, err = (&Generator{Length: 2, Validators: Validators{}}).Generate(nil)
+	assertion.Equal(ErrLengthTooShort, err)
+}
@@ -0,0 +1,82 @@
+package password
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// scrypt 的默认参数，与 django 的 ScryptPasswordHasher 一致
+const (
+	DEFAULT_SCRYPT_N          = 1 << 14 // work factor
+	DEFAULT_SCRYPT_R          = 8       // block size
+	DEFAULT_SCRYPT_P          = 1       // parallelism
+	DEFAULT_SCRYPT_KEY_LENGTH = 64
+)
+
+// ScryptHasher 与 django 的 ScryptPasswordHasher 格式兼容：scrypt$<n>$<盐>$<r>$<p>$<base64哈希>
+type ScryptHasher struct {
+	N int // 0为默认值
+	R int // 0为默认值
+	P int // 0为默认值
+}
+
+func (h *ScryptHasher) Algorithm() string {
+	return "scrypt"
+}
+
+func (h *ScryptHasher) Encode(password string) (string, error) {
+	salt, err := newSalt()
+	if err != nil {
+		return "", err
+	}
+	n, r, p := h.params()
+	hash, err := scrypt.Key([]byte(password), []byte(salt), n, r, p, DEFAULT_SCRYPT_KEY_LENGTH)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join([]string{
+		h.Algorithm(), strconv.Itoa(n), salt, strconv.Itoa(r), strconv.Itoa(p),
+		base64.StdEncoding.EncodeToString(hash),
+	}, "$"), nil
+}
+
+func (h *ScryptHasher) Verify(password, encoded string) bool {
+	decoded, err := ParseEncoded(encoded)
+	if err != nil || decoded.Algorithm != h.Algorithm() {
+		return false
+	}
+	expected, _ := base64.StdEncoding.DecodeString(decoded.Hash)
+	hash, err := scrypt.Key([]byte(password), []byte(decoded.Salt), decoded.Iterations, decoded.BlockSize, decoded.Parallelism, len(expected))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(hash, expected) == 1
+}
+
+// MustUpdate n、r、p与当前设置不同，或盐的熵不足时需要更新
+func (h *ScryptHasher) MustUpdate(encoded string) bool {
+	decoded, err := ParseEncoded(encoded)
+	if err != nil || decoded.Algorithm != h.Algorithm() {
+		return false
+	}
+	n, r, p := h.params()
+	return decoded.Iterations != n || decoded.BlockSize != r || decoded.Parallelism != p || mustUpdateSalt(decoded.Salt)
+}
+
+func (h *ScryptHasher) params() (n, r, p int) {
+	n, r, p = h.N, h.R, h.P
+	if n == 0 {
+		n = DEFAULT_SCRYPT_N
+	}
+	if r == 0 {
+		r = DEFAULT_SCRYPT_R
+	}
+	if p == 0 {
+		p = DEFAULT_SCRYPT_P
+	}
+	return
+}
@@ -0,0 +1,94 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// DEFAULT_MAX_SIMILARITY 与 django 的 UserAttributeSimilarityValidator 一致
+const DEFAULT_MAX_SIMILARITY = 0.7
+
+// DEFAULT_USER_ATTRIBUTES 与 django 的 UserAttributeSimilarityValidator.DEFAULT_USER_ATTRIBUTES 一致
+var DEFAULT_USER_ATTRIBUTES = []string{"username", "first_name", "last_name", "email"}
+
+var ErrInvalidMaxSimilarity = errors.New("max similarity must be at least 0.1")
+
+// UserAttributeSimilarityValidator 密码不能与用户的属性(用户名、姓名、邮箱等)过于相似。
+// 属性会按非单词字符拆开分别比较，如邮箱 john.smith@example.com 会与 john、smith、example、com 分别比较
+type UserAttributeSimilarityValidator struct {
+	UserAttributes []string // 要比较的属性名，为空时使用DEFAULT_USER_ATTRIBUTES
+	MaxSimilarity  float64  // 相似度达到该值时校验不通过，取值0.1~1，0为默认值DEFAULT_MAX_SIMILARITY
+}
+
+func (v *UserAttributeSimilarityValidator) Validate(password string, user UserAttributes) error {
+	if len(user) == 0 {
+		return nil
+	}
+	maxSimilarity := v.MaxSimilarity
+	if maxSimilarity == 0 {
+		maxSimilarity = DEFAULT_MAX_SIMILARITY
+	}
+	if maxSimilarity < 0.1 {
+		return ErrInvalidMaxSimilarity
+	}
+	attributes := v.UserAttributes
+	if len(attributes) == 0 {
+		attributes = DEFAULT_USER_ATTRIBUTES
+	}
+
+	password = strings.ToLower(password)
+	for _, attribute := range attributes {
+		value := strings.ToLower(user[attribute])
+		if value == "" {
+			continue
+		}
+		parts := append(strings.FieldsFunc(value, isNonWordRune), value)
+		for _, part := range parts {
+			if exceedsMaximumLengthRatio(password, maxSimilarity, part) {
+				continue
+			}
+			if quickRatio(password, part) >= maxSimilarity {
+				return &ValidationError{
+					Code:    CODE_PASSWORD_TOO_SIMILAR,
+					Message: "The password is too similar to the " + strings.ReplaceAll(attribute, "_", " ") + ".",
+					Params:  map[string]any{"verbose_name": attribute},
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// isNonWordRune 对应正则的 \W
+func isNonWordRune(r rune) bool {
+	return !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_')
+}
+
+// exceedsMaximumLengthRatio 密码比属性长得多时不可能相似，跳过比较，与 django 一致
+func exceedsMaximumLengthRatio(password string, maxSimilarity float64, value string) bool {
+	passwordLength := float64(len([]rune(password)))
+	valueLength := float64(len([]rune(value)))
+	return passwordLength >= 10*valueLength && valueLength < maxSimilarity/2*passwordLength
+}
+
+// quickRatio python difflib.SequenceMatcher.quick_ratio：两个字符串共有字符数*2/总长度，是相似度的上限
+func quickRatio(a, b string) float64 {
+	ar, br := []rune(a), []rune(b)
+	total := len(ar) + len(br)
+	if total == 0 {
+		return 1
+	}
+	counts := make(map[rune]int, len(br))
+	for _, r := range br {
+		counts[r]++
+	}
+	matches := 0
+	for _, r := range ar {
+		if counts[r] > 0 {
+			counts[r]--
+			matches++
+		}
+	}
+	return 2 * float64(matches) / float64(total)
+}
@@ -0,0 +1,185 @@
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 校验错误的code，与 django 的密码校验器一致，用于本地化错误信息
+const (
+	CODE_PASSWORD_TOO_SHORT        = "password_too_short"
+	CODE_PASSWORD_TOO_SIMILAR      = "password_too_similar"
+	CODE_PASSWORD_TOO_COMMON       = "password_too_common"
+	CODE_PASSWORD_ENTIRELY_NUMERIC = "password_entirely_numeric"
+	CODE_PASSWORD_NO_UPPER         = "password_no_upper"
+	CODE_PASSWORD_NO_LOWER         = "password_no_lower"
+	CODE_PASSWORD_NO_DIGIT         = "password_no_digit"
+	CODE_PASSWORD_NO_SYMBOL        = "password_no_symbol"
+	CODE_PASSWORD_TOO_FEW_CLASSES  = "password_too_few_character_classes"
+)
+
+// DEFAULT_MIN_LENGTH 与 django 的 MinimumLengthValidator 一致
+const DEFAULT_MIN_LENGTH = 8
+
+// ValidationError 一条校验错误。Message 为英文的默认信息，API 可根据 Code 和 Params 生成本地化的信息
+type ValidationError struct {
+	Code    string         `json:"code"`
+	Message string         `json:"message"`
+	Params  map[string]any `json:"params,omitempty"`
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// ValidationErrors 校验不通过时返回的所有错误
+type ValidationErrors []*ValidationError
+
+func (errs ValidationErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Message
+	}
+	return strings.Join(messages, " ")
+}
+
+// UserAttributes 用户的属性，key为属性名，如 username、first_name、last_name、email，用于 UserAttributeSimilarityValidator
+type UserAttributes map[string]string
+
+// Validator 密码校验器，对应 django 的 AUTH_PASSWORD_VALIDATORS 中的一项。
+// 校验不通过时返回 *ValidationError 或 ValidationErrors，user可为nil
+type Validator interface {
+	Validate(password string, user UserAttributes) error
+}
+
+// Validators 依次执行的一组校验器
+type Validators []Validator
+
+// DefaultValidators ValidatePassword 使用的校验器，与 django 新建项目时默认的 AUTH_PASSWORD_VALIDATORS 相同
+var DefaultValidators = Validators{
+	&UserAttributeSimilarityValidator{},
+	&MinimumLengthValidator{},
+	&CommonPasswordValidator{},
+	&NumericPasswordValidator{},
+}
+
+// Validate 执行所有校验器，全部通过时返回nil，否则返回所有错误(ValidationErrors)，对应 django 的 validate_password
+func (validators Validators) Validate(password string, user UserAttributes) error {
+	var errs ValidationErrors
+	for _, validator := range validators {
+		err := validator.Validate(password, user)
+		switch e := err.(type) {
+		case nil:
+		case *ValidationError:
+			errs = append(errs, e)
+		case ValidationErrors:
+			errs = append(errs, e...)
+		default:
+			return err
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ValidatePassword 使用 DefaultValidators 校验密码
+func ValidatePassword(password string, user UserAttributes) error {
+	return DefaultValidators.Validate(password, user)
+}
+
+// MinimumLengthValidator 密码的最短长度(字符数)
+type MinimumLengthValidator struct {
+	MinLength int // 0为默认值DEFAULT_MIN_LENGTH
+}
+
+func (v *MinimumLengthValidator) Validate(password string, user UserAttributes) error {
+	minLength := v.MinLength
+	if minLength == 0 {
+		minLength = DEFAULT_MIN_LENGTH
+	}
+	if utf8.RuneCountInString(password) >= minLength {
+		return nil
+	}
+	return &ValidationError{
+		Code:    CODE_PASSWORD_TOO_SHORT,
+		Message: fmt.Sprintf("This password is too short. It must contain at least %d characters.", minLength),
+		Params:  map[string]any{"min_length": minLength},
+	}
+}
+
+// NumericPasswordValidator 密码不能全是数字
+type NumericPasswordValidator struct{}
+
+func (v *NumericPasswordValidator) Validate(password string, user UserAttributes) error {
+	if password == "" {
+		return nil
+	}
+	for _, r := range password {
+		if !unicode.IsDigit(r) {
+			return nil
+		}
+	}
+	return &ValidationError{
+		Code:    CODE_PASSWORD_ENTIRELY_NUMERIC,
+		Message: "This password is entirely numeric.",
+	}
+}
+
+// CharacterClassValidator 要求密码包含的字符类型。Require*为必须包含的类型，MinClasses为至少包含几种类型(大写、小写、数字、符号)
+type CharacterClassValidator struct {
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	MinClasses    int
+}
+
+func (v *CharacterClassValidator) Validate(password string, user UserAttributes) error {
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	var errs ValidationErrors
+	if v.RequireUpper && !hasUpper {
+		errs = append(errs, &ValidationError{Code: CODE_PASSWORD_NO_UPPER, Message: "This password must contain at least one uppercase letter."})
+	}
+	if v.RequireLower && !hasLower {
+		errs = append(errs, &ValidationError{Code: CODE_PASSWORD_NO_LOWER, Message: "This password must contain at least one lowercase letter."})
+	}
+	if v.RequireDigit && !hasDigit {
+		errs = append(errs, &ValidationError{Code: CODE_PASSWORD_NO_DIGIT, Message: "This password must contain at least one digit."})
+	}
+	if v.RequireSymbol && !hasSymbol {
+		errs = append(errs, &ValidationError{Code: CODE_PASSWORD_NO_SYMBOL, Message: "This password must contain at least one symbol."})
+	}
+	classes := 0
+	for _, has := range []bool{hasUpper, hasLower, hasDigit, hasSymbol} {
+		if has {
+			classes++
+		}
+	}
+	if classes < v.MinClasses {
+		errs = append(errs, &ValidationError{
+			Code:    CODE_PASSWORD_TOO_FEW_CLASSES,
+			Message: fmt.Sprintf("This password must contain at least %d of: uppercase letters, lowercase letters, digits and symbols.", v.MinClasses),
+			Params:  map[string]any{"min_classes": v.MinClasses},
+		})
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// codesOf 返回校验错误的code
+func codesOf(t *testing.T, err error) []string {
+	var errs ValidationErrors
+	require.True(t, errors.As(err, &errs), "%v", err)
+	codes := make([]string, len(errs))
+	for i, e := range errs {
+		codes[i] = e.Code
+	}
+	return codes
+}
+
+func TestValidatePassword(t *testing.T) {
+	assertion := assert.New(t)
+	user := UserAttributes{"username": "johnsmith", "email": "john.smith@example.com", "first_name": "John"}
+
+	assertion.NoError(ValidatePassword("tr0ub4dor&3-horse", user))
+	assertion.Equal([]string{CODE_PASSWORD_TOO_SHORT, CODE_PASSWORD_TOO_COMMON, CODE_PASSWORD_ENTIRELY_NUMERIC}, codesOf(t, ValidatePassword("123456", nil)))
+	assertion.Equal([]string{CODE_PASSWORD_TOO_COMMON}, codesOf(t, ValidatePassword("Password", nil)))
+	assertion.Equal([]string{CODE_PASSWORD_TOO_SIMILAR}, codesOf(t, ValidatePassword("johnsmith1", user)))
+
+	err := ValidatePassword("short", nil)
+	var errs ValidationErrors
+	require.True(t, errors.As(err, &errs))
+	assertion.Equal(map[string]any{"min_length": DEFAULT_MIN_LENGTH}, errs[0].Params)
+	assertion.True(strings.HasPrefix(err.Error(), "This password is too short."))
+}
+
+func TestUserAttributeSimilarityValidator(t *testing.T) {
+	assertion := assert.New(t)
+	validator := &UserAttributeSimilarityValidator{}
+	user := UserAttributes{"username": "testclient", "email": "testclient@example.com", "first_name": "Test", "last_name": "Client"}
+
+	err := validator.Validate("testclient", user)
+	require.Error(t, err)
+	assertion.Equal(map[string]any{"verbose_name": "username"}, err.(*ValidationError).Params)
+
+	// 邮箱按非单词字符拆开比较
+	err = validator.Validate("example.com", UserAttributes{"email": "someone@example.com"})
+	require.Error(t, err)
+	assertion.Equal("The password is too similar to the email.", err.Error())
+
+	assertion.NoError(validator.Validate("testclient", nil))
+	assertion.NoError(validator.Validate("a very different password", user))
+	assertion.NoError((&UserAttributeSimilarityValidator{MaxSimilarity: 1}).Validate("clienttest", UserAttributes{"username": "testclients"}))
+	assertion.Error((&UserAttributeSimilarityValidator{MaxSimilarity: 1}).Validate("clienttest", UserAttributes{"username": "testclient"}))
+	assertion.Equal(ErrInvalidMaxSimilarity, (&UserAttributeSimilarityValidator{MaxSimilarity: 0.05}).Validate("x", user))
+
+	// 与 python difflib 的 quick_ratio 结果一致
+	assertion.InDelta(0.75, quickRatio("abcd", "bcde"), 1e-9)
+	assertion.InDelta(1.0, quickRatio("", ""), 1e-9)
+}
+
+func TestCommonPasswordValidator(t *testing.T) {
+	assertion := assert.New(t)
+	validator := &CommonPasswordValidator{}
+	assertion.Error(validator.Validate("password", nil))
+	assertion.Error(validator.Validate(" QWERTY ", nil))
+	assertion.NoError(validator.Validate("tr0ub4dor&3-horse", nil))
+	assertion.Len(defaultPasswords(), 20000)
+
+	passwords, err := LoadCommonPasswords(strings.NewReader("Hunter2\n\nletmein\n"))
+	require.NoError(t, err)
+	custom := &CommonPasswordValidator{Passwords: passwords}
+	assertion.Error(custom.Validate("hunter2", nil))
+	assertion.NoError(custom.Validate("password", nil))
+}
+
+func TestCharacterClassValidator(t *testing.T) {
+	assertion := assert.New(t)
+	validator := &CharacterClassValidator{RequireUpper: true, RequireDigit: true, MinClasses: 3}
+	assertion.NoError(validator.Validate("Passw0rd", nil))
+	assertion.Equal([]string{CODE_PASSWORD_NO_UPPER, CODE_PASSWORD_NO_DIGIT, CODE_PASSWORD_TOO_FEW_CLASSES}, codesOf(t, validator.Validate("password", nil)))
+	assertion.NoError((&CharacterClassValidator{RequireSymbol: true}).Validate("pass word", nil))
+
+	validators := Validators{&MinimumLengthValidator{MinLength: 12}, validator}
+	assertion.Equal([]string{CODE_PASSWORD_TOO_SHORT, CODE_PASSWORD_NO_DIGIT, CODE_PASSWORD_TOO_FEW_CLASSES}, codesOf(t, validators.Validate("Password", nil)))
+	assertion.Equal([]string{CODE_PASSWORD_ENTIRELY_NUMERIC}, codesOf(t, Validators{&NumericPasswordValidator{}}.Validate("٣٤٥", nil)))
+}
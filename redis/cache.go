@@ -0,0 +1,181 @@
+package redis
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/vmihailenco/msgpack/v5"
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrNotFound is returned by a Cache loader when the value does not exist.
+// With Cache.NegativeTTL set, it is cached as well, so repeated lookups of a missing row do not reach the database.
+var ErrNotFound = errors.New("redis: cached value not found")
+
+// Cached values are prefixed with a marker byte, so a negative entry cannot be mistaken for an encoded value.
+const (
+	cacheValueMarker    = 'v'
+	cacheNotFoundMarker = 'n'
+)
+
+// Codec encodes the values stored by a Cache.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// Codecs for Cache.Codec.
+var (
+	JSONCodec    Codec = jsonCodec{}
+	MsgpackCodec Codec = msgpackCodec{}
+	GobCodec     Codec = gobCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(v any) ([]byte, error)      { return msgpack.Marshal(v) }
+func (msgpackCodec) Unmarshal(data []byte, v any) error { return msgpack.Unmarshal(data, v) }
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// Cache is a typed cache of T values on top of RedisClient.
+// A Cache must not be copied after first use.
+type Cache[T any] struct {
+	Redis  *RedisClient
+	Prefix string // prepended to every key, e.g. "user:"
+	Codec  Codec  // nil means JSONCodec
+	// NegativeTTL is how long a loader's ErrNotFound is cached; 0 disables negative caching.
+	NegativeTTL time.Duration
+	// Jitter randomly extends each TTL by up to this fraction, e.g. 0.1 for up to 10%,
+	// so keys written together do not all expire at the same moment.
+	Jitter float64
+
+	group singleflight.Group
+}
+
+// NewCache creates a Cache using JSONCodec.
+func NewCache[T any](client *RedisClient, prefix string) *Cache[T] {
+	return &Cache[T]{Redis: client, Prefix: prefix}
+}
+
+// Get returns the cached value of key. It returns redis.Nil if the key is not cached,
+// and ErrNotFound if a negative entry is cached.
+func (c *Cache[T]) Get(ctx context.Context, key string) (T, error) {
+	var value T
+	data, err := c.Redis.GetContext(ctx, c.Prefix+key)
+	if err != nil {
+		return value, err
+	}
+	if len(data) == 0 {
+		return value, redis.Nil
+	}
+	switch data[0] {
+	case cacheNotFoundMarker:
+		return value, ErrNotFound
+	case cacheValueMarker:
+		err = c.codec().Unmarshal(data[1:], &value)
+		return value, err
+	}
+	return value, errors.New("redis: unknown cache entry")
+}
+
+// Set caches value for ttl, extended by Jitter. A ttl of 0 means no expiration.
+func (c *Cache[T]) Set(ctx context.Context, key string, value T, ttl time.Duration) error {
+	data, err := c.codec().Marshal(value)
+	if err != nil {
+		return err
+	}
+	return c.Redis.SetContext(ctx, c.Prefix+key, append([]byte{cacheValueMarker}, data...), c.jitter(ttl))
+}
+
+// SetNotFound caches a negative entry for NegativeTTL, so Get and GetOrLoad return ErrNotFound.
+func (c *Cache[T]) SetNotFound(ctx context.Context, key string) error {
+	if c.NegativeTTL <= 0 {
+		return nil
+	}
+	return c.Redis.SetContext(ctx, c.Prefix+key, []byte{cacheNotFoundMarker}, c.jitter(c.NegativeTTL))
+}
+
+// Delete removes keys from the cache, e.g. after the underlying rows change.
+func (c *Cache[T]) Delete(ctx context.Context, keys ...string) error {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = c.Prefix + key
+	}
+	_, err := c.Redis.DeleteContext(ctx, prefixed...)
+	return err
+}
+
+// GetOrLoad returns the cached value of key. On a miss it calls loader and caches the result for ttl.
+// Concurrent misses of the same key in this process share one loader call. If loader returns ErrNotFound
+// and NegativeTTL is set, the miss is cached too. The cache is best effort: when Redis fails or holds a value
+// that cannot be decoded, loader is still called and its result returned.
+func (c *Cache[T]) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader func(ctx context.Context) (T, error)) (T, error) {
+	value, err := c.Get(ctx, key)
+	if err == nil || errors.Is(err, ErrNotFound) {
+		return value, err
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return value, ctxErr
+	}
+
+	// The shared load must not fail for everyone when the first caller gives up, so it ignores that caller's
+	// cancellation; each caller still stops waiting when its own context is done.
+	loadCtx := context.WithoutCancel(ctx)
+	ch := c.group.DoChan(key, func() (any, error) {
+		loaded, err := loader(loadCtx)
+		if errors.Is(err, ErrNotFound) {
+			c.SetNotFound(loadCtx, key)
+			return loaded, err
+		}
+		if err != nil {
+			return loaded, err
+		}
+		c.Set(loadCtx, key, loaded, ttl)
+		return loaded, nil
+	})
+	select {
+	case result := <-ch:
+		value, _ = result.Val.(T)
+		return value, result.Err
+	case <-ctx.Done():
+		return value, ctx.Err()
+	}
+}
+
+func (c *Cache[T]) codec() Codec {
+	if c.Codec == nil {
+		return JSONCodec
+	}
+	return c.Codec
+}
+
+func (c *Cache[T]) jitter(ttl time.Duration) time.Duration {
+	if ttl <= 0 || c.Jitter <= 0 {
+		return ttl
+	}
+	return ttl + time.Duration(rand.Float64()*c.Jitter*float64(ttl))
+}
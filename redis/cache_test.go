@@ -0,0 +1,106 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type cachedUser struct {
+	ID   int
+	Name string
+}
+
+func TestCacheCodecs(t *testing.T) {
+	ctx := context.Background()
+	for name, codec := range map[string]Codec{"json": JSONCodec, "msgpack": MsgpackCodec, "gob": GobCodec} {
+		t.Run(name, func(t *testing.T) {
+			cache := NewCache[cachedUser](r, "test:cache:"+name+":")
+			cache.Codec = codec
+			defer cache.Delete(ctx, "1")
+
+			_, err := cache.Get(ctx, "1")
+			assert.Equal(t, redis.Nil, err)
+
+			require.NoError(t, cache.Set(ctx, "1", cachedUser{ID: 1, Name: "john"}, 10*time.Second))
+			user, err := cache.Get(ctx, "1")
+			require.NoError(t, err)
+			assert.Equal(t, cachedUser{ID: 1, Name: "john"}, user)
+		})
+	}
+}
+
+func TestCacheGetOrLoad(t *testing.T) {
+	ctx := context.Background()
+	cache := NewCache[cachedUser](r, "test:cache:load:")
+	cache.NegativeTTL = 10 * time.Second
+	cache.Jitter = 0.5
+	defer cache.Delete(ctx, "1", "2", "3")
+
+	// Concurrent misses share one loader call
+	var calls atomic.Int32
+	loader := func(ctx context.Context) (cachedUser, error) {
+		calls.Add(1)
+		time.Sleep(100 * time.Millisecond)
+		return cachedUser{ID: 1, Name: "john"}, nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			user, err := cache.GetOrLoad(ctx, "1", 10*time.Second, loader)
+			assert.NoError(t, err)
+			assert.Equal(t, "john", user.Name)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), calls.Load())
+
+	// Cached now, with the TTL extended by up to 50%
+	_, err := cache.GetOrLoad(ctx, "1", 10*time.Second, loader)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), calls.Load())
+	ttl, err := r.TTL("test:cache:load:1")
+	require.NoError(t, err)
+	assert.True(t, ttl > 9*time.Second && ttl <= 15*time.Second, ttl)
+
+	// Negative caching
+	notFound := func(ctx context.Context) (cachedUser, error) {
+		calls.Add(1)
+		return cachedUser{}, ErrNotFound
+	}
+	for i := 0; i < 2; i++ {
+		_, err = cache.GetOrLoad(ctx, "2", 10*time.Second, notFound)
+		assert.ErrorIs(t, err, ErrNotFound)
+	}
+	assert.Equal(t, int32(2), calls.Load())
+
+	// Other loader errors are not cached
+	failed := errors.New("database is down")
+	_, err = cache.GetOrLoad(ctx, "3", 10*time.Second, func(ctx context.Context) (cachedUser, error) {
+		return cachedUser{}, failed
+	})
+	assert.ErrorIs(t, err, failed)
+	_, err = cache.Get(ctx, "3")
+	assert.Equal(t, redis.Nil, err)
+
+	// A caller stops waiting when its context is done
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = cache.GetOrLoad(timeout, "3", 10*time.Second, loader)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The shared load still completes for the other callers
+	time.Sleep(200 * time.Millisecond)
+	user, err := cache.Get(ctx, "3")
+	require.NoError(t, err)
+	assert.Equal(t, "john", user.Name)
+}
@@ -0,0 +1,270 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// LOCK_PREFIX is prepended to lock keys. The name is wrapped in a {hash tag}, so the lock
+// and its fencing counter live in the same cluster slot and can be used by one script.
+const LOCK_PREFIX = "lock:"
+
+// Lock defaults.
+const (
+	DEFAULT_LOCK_TTL             = 30 * time.Second
+	DEFAULT_LOCK_RETRY_DELAY     = 50 * time.Millisecond
+	DEFAULT_LOCK_MAX_RETRY_DELAY = time.Second
+	// MIN_LOCK_TTL is the shortest lock TTL; Redis expires keys in whole milliseconds.
+	MIN_LOCK_TTL = time.Millisecond
+)
+
+var (
+	ErrLockNotAcquired = errors.New("redis: lock not acquired")
+	ErrLockNotHeld     = errors.New("redis: lock not held")
+	ErrInvalidLockTTL  = errors.New("redis: lock ttl must be at least 1ms")
+)
+
+// obtainScript sets the lock if it is free and returns the next fencing token, or 0 if the lock is taken.
+// The fencing counter never expires, so tokens keep increasing across holders.
+var obtainScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return redis.call("INCR", KEYS[2])
+end
+return 0
+`)
+
+// releaseScript deletes the lock only if it is still held by this owner.
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// extendScript resets the lock's TTL only if it is still held by this owner.
+var extendScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// LockOptions configures a Locker. Zero values use the defaults.
+type LockOptions struct {
+	// TTL is how long the lock is held unless released or extended; 0 means DEFAULT_LOCK_TTL,
+	// otherwise it must be at least MIN_LOCK_TTL.
+	TTL time.Duration
+	// RetryDelay is the first wait between attempts of Obtain; it doubles up to MaxRetryDelay, with jitter.
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+	// Watchdog extends the lock every TTL/3 while it is held, so work that outlives TTL keeps the lock.
+	// Failed extensions are retried until the lock would have expired; then, or as soon as the lock turns out
+	// to be held by someone else, Lock.Lost is closed and the holder should stop writing to the protected resource.
+	Watchdog bool
+}
+
+// Locker obtains distributed locks. The lock value is a random owner token, so a lock can only be
+// released or extended by its holder, never by a client whose lock has expired and been taken by another.
+type Locker struct {
+	Redis   *RedisClient
+	Options LockOptions
+}
+
+// NewLocker creates a Locker. It returns ErrInvalidLockTTL if options.TTL is shorter than MIN_LOCK_TTL.
+func NewLocker(client *RedisClient, options LockOptions) (*Locker, error) {
+	if err := validLockTTL(options.TTL); err != nil {
+		return nil, err
+	}
+	return &Locker{Redis: client, Options: options}, nil
+}
+
+// Lock is a held lock.
+type Lock struct {
+	locker  *Locker
+	key     string
+	owner   string
+	fencing int64
+
+	mu       sync.Mutex
+	released bool
+	stop     chan struct{}
+	lost     chan struct{}
+}
+
+// TryObtain makes a single attempt to take the lock on name. It returns ErrLockNotAcquired if the lock is held.
+func (l *Locker) TryObtain(ctx context.Context, name string) (*Lock, error) {
+	if err := validLockTTL(l.Options.TTL); err != nil {
+		return nil, err
+	}
+	// The owner token is a random UUID, so only this holder can release or extend the lock
+	owner := uuid.NewString()
+	key := LOCK_PREFIX + "{" + name + "}"
+	fencing, err := obtainScript.Run(ctx, l.Redis.Client, []string{key, key + ":fencing"}, owner, l.ttl().Milliseconds()).Int64()
+	if err != nil {
+		return nil, err
+	}
+	if fencing == 0 {
+		return nil, ErrLockNotAcquired
+	}
+	lock := &Lock{locker: l, key: key, owner: owner, fencing: fencing, stop: make(chan struct{}), lost: make(chan struct{})}
+	if l.Options.Watchdog {
+		go lock.watchdog()
+	}
+	return lock, nil
+}
+
+// Obtain takes the lock on name, retrying with exponential backoff until it succeeds or ctx is done.
+// Use a context with a timeout to bound the wait.
+func (l *Locker) Obtain(ctx context.Context, name string) (*Lock, error) {
+	delay := l.Options.RetryDelay
+	if delay <= 0 {
+		delay = DEFAULT_LOCK_RETRY_DELAY
+	}
+	maxDelay := l.Options.MaxRetryDelay
+	if maxDelay <= 0 {
+		maxDelay = DEFAULT_LOCK_MAX_RETRY_DELAY
+	}
+	for {
+		lock, err := l.TryObtain(ctx, name)
+		if !errors.Is(err, ErrLockNotAcquired) {
+			return lock, err
+		}
+		// Wait between half and all of delay, so waiting clients do not retry in step.
+		timer := time.NewTimer(delay/2 + rand.N(delay/2+1))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		delay = min(delay*2, maxDelay)
+	}
+}
+
+// Key is the Redis key of the lock.
+func (lock *Lock) Key() string {
+	return lock.key
+}
+
+// FencingToken increases every time the lock is obtained. Pass it to the protected resource, which
+// should reject writes carrying a smaller token than one it has seen: they come from a holder whose
+// lock expired, e.g. after a long GC pause.
+func (lock *Lock) FencingToken() int64 {
+	return lock.fencing
+}
+
+// Lost is closed when the watchdog fails to extend the lock, i.e. the lock may now be held by someone else.
+func (lock *Lock) Lost() <-chan struct{} {
+	return lock.lost
+}
+
+// TTL returns the remaining time to live of the lock, or ErrLockNotHeld if it is no longer held by this owner.
+func (lock *Lock) TTL(ctx context.Context) (time.Duration, error) {
+	client := lock.locker.Redis.Client
+	value, err := client.Get(ctx, lock.key).Result()
+	if errors.Is(err, redis.Nil) || (err == nil && value != lock.owner) {
+		return 0, ErrLockNotHeld
+	}
+	if err != nil {
+		return 0, err
+	}
+	return client.PTTL(ctx, lock.key).Result()
+}
+
+// Extend resets the lock's TTL to ttl; 0 means the Locker's TTL. It returns ErrInvalidLockTTL if ttl is
+// shorter than MIN_LOCK_TTL, and ErrLockNotHeld
+// if the lock has expired or been released.
+func (lock *Lock) Extend(ctx context.Context, ttl time.Duration) error {
+	if ttl == 0 {
+		ttl = lock.locker.ttl()
+	}
+	if err := validLockTTL(ttl); err != nil {
+		return err
+	}
+	ok, err := extendScript.Run(ctx, lock.locker.Redis.Client, []string{lock.key}, lock.owner, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// Release releases the lock and stops the watchdog. It returns ErrLockNotHeld if the lock
+// had already expired or been released; the lock is then left alone, as another client may hold it.
+func (lock *Lock) Release(ctx context.Context) error {
+	lock.mu.Lock()
+	if !lock.released {
+		lock.released = true
+		close(lock.stop)
+	}
+	lock.mu.Unlock()
+
+	n, err := releaseScript.Run(ctx, lock.locker.Redis.Client, []string{lock.key}, lock.owner).Int64()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// watchdog extends the lock every TTL/3 until it is released or lost. A failed extension, e.g. a network
+// error, is retried until the lock would have expired since the last successful one.
+func (lock *Lock) watchdog() {
+	ttl := lock.locker.ttl()
+	interval := ttl / 3
+	expiresAt := time.Now().Add(ttl)
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-lock.stop:
+			return
+		case <-timer.C:
+		}
+		remaining := time.Until(expiresAt)
+		if remaining <= 0 {
+			close(lock.lost)
+			return
+		}
+		// Give up on an extension that has not succeeded before the lock would expire anyway.
+		start := time.Now()
+		ctx, cancel := context.WithTimeout(context.Background(), min(interval, remaining))
+		err := lock.Extend(ctx, ttl)
+		cancel()
+		switch {
+		case err == nil:
+			expiresAt = start.Add(ttl)
+			timer.Reset(interval)
+		case errors.Is(err, ErrLockNotHeld):
+			close(lock.lost)
+			return
+		default:
+			timer.Reset(min(interval/4, time.Until(expiresAt)))
+		}
+	}
+}
+
+func (l *Locker) ttl() time.Duration {
+	if l.Options.TTL <= 0 {
+		return DEFAULT_LOCK_TTL
+	}
+	return l.Options.TTL
+}
+
+// validLockTTL checks a TTL given in the options; 0 means the default.
+func validLockTTL(ttl time.Duration) error {
+	if ttl != 0 && ttl < MIN_LOCK_TTL {
+		return ErrInvalidLockTTL
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLock(t *testing.T) {
+	ctx := context.Background()
+	locker, err := NewLocker(r, LockOptions{TTL: time.Second, RetryDelay: 10 * time.Millisecond})
+	require.NoError(t, err)
+	defer r.Delete(LOCK_PREFIX+"{test}", LOCK_PREFIX+"{test}:fencing")
+
+	lock, err := locker.TryObtain(ctx, "test")
+	require.NoError(t, err)
+	assert.Equal(t, "lock:{test}", lock.Key())
+	assert.Equal(t, ErrInvalidLockTTL, lock.Extend(ctx, time.Microsecond))
+	ttl, err := lock.TTL(ctx)
+	require.NoError(t, err)
+	assert.True(t, ttl > 0 && ttl <= time.Second, ttl)
+
+	// Held by someone else
+	_, err = locker.TryObtain(ctx, "test")
+	assert.Equal(t, ErrLockNotAcquired, err)
+	timeout, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	_, err = locker.Obtain(timeout, "test")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	require.NoError(t, lock.Extend(ctx, 5*time.Second))
+	ttl, err = lock.TTL(ctx)
+	require.NoError(t, err)
+	assert.True(t, ttl > time.Second, ttl)
+
+	// Obtain waits for the release; the fencing token increases
+	released := make(chan struct{})
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		assert.NoError(t, lock.Release(ctx))
+		close(released)
+	}()
+	next, err := locker.Obtain(ctx, "test")
+	require.NoError(t, err)
+	<-released
+	assert.Greater(t, next.FencingToken(), lock.FencingToken())
+
+	// The old holder can no longer release, extend or read the lock
+	assert.Equal(t, ErrLockNotHeld, lock.Release(ctx))
+	assert.Equal(t, ErrLockNotHeld, lock.Extend(ctx, time.Second))
+	_, err = lock.TTL(ctx)
+	assert.Equal(t, ErrLockNotHeld, err)
+	require.NoError(t, next.Release(ctx))
+}
+
+func TestLockWatchdog(t *testing.T) {
+	ctx := context.Background()
+	locker, err := NewLocker(r, LockOptions{TTL: 300 * time.Millisecond, Watchdog: true})
+	require.NoError(t, err)
+	defer r.Delete(LOCK_PREFIX+"{test:watchdog}", LOCK_PREFIX+"{test:watchdog}:fencing")
+
+	lock, err := locker.TryObtain(ctx, "test:watchdog")
+	require.NoError(t, err)
+
+	// Still held after several TTLs
+	time.Sleep(time.Second)
+	_, err = locker.TryObtain(ctx, "test:watchdog")
+	assert.Equal(t, ErrLockNotAcquired, err)
+	select {
+	case <-lock.Lost():
+		t.Fatal("lock lost")
+	default:
+	}
+
+	// The watchdog notices when the lock is taken away
+	_, err = r.Delete(lock.Key())
+	require.NoError(t, err)
+	select {
+	case <-lock.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("lock not reported as lost")
+	}
+	assert.Equal(t, ErrLockNotHeld, lock.Release(ctx))
+}
+
+func TestLockTTLValidation(t *testing.T) {
+	for _, ttl := range []time.Duration{time.Microsecond, 999 * time.Microsecond, -time.Second} {
+		_, err := NewLocker(r, LockOptions{TTL: ttl})
+		assert.Equal(t, ErrInvalidLockTTL, err, ttl)
+		locker := &Locker{Redis: r, Options: LockOptions{TTL: ttl, Watchdog: true}}
+		_, err = locker.TryObtain(context.Background(), "test:ttl")
+		assert.Equal(t, ErrInvalidLockTTL, err, ttl)
+	}
+	locker, err := NewLocker(r, LockOptions{TTL: MIN_LOCK_TTL, Watchdog: true})
+	require.NoError(t, err)
+	lock, err := locker.TryObtain(context.Background(), "test:ttl")
+	require.NoError(t, err)
+	defer r.Delete(lock.Key(), lock.Key()+":fencing")
+	_ = lock.Release(context.Background())
+}
+
+// failingHook fails every command while failing is set, like a network outage.
+type failingHook struct {
+	failing atomic.Bool
+}
+
+func (h *failingHook) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (h *failingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if h.failing.Load() {
+			err := errors.New("connection reset")
+			cmd.SetErr(err)
+			return err
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func (h *failingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func TestLockWatchdogTransientError(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewRedisClient("localhost:6379", "", 0)
+	require.NoError(t, err)
+	defer client.Close()
+	hook := &failingHook{}
+	client.Client.AddHook(hook)
+	locker, err := NewLocker(client, LockOptions{TTL: 600 * time.Millisecond, Watchdog: true})
+	require.NoError(t, err)
+	defer r.Delete(LOCK_PREFIX+"{test:outage}", LOCK_PREFIX+"{test:outage}:fencing")
+
+	lock, err := locker.TryObtain(ctx, "test:outage")
+	require.NoError(t, err)
+
+	// A short outage does not lose the lock
+	hook.failing.Store(true)
+	time.Sleep(300 * time.Millisecond)
+	hook.failing.Store(false)
+	time.Sleep(400 * time.Millisecond)
+	select {
+	case <-lock.Lost():
+		t.Fatal("lock lost after a transient error")
+	default:
+	}
+
+	// An outage longer than the TTL does
+	hook.failing.Store(true)
+	select {
+	case <-lock.Lost():
+	case <-time.After(2 * time.Second):
+		t.Fatal("lock not reported as lost")
+	}
+	hook.failing.Store(false)
+	assert.Equal(t, ErrLockNotHeld, lock.Release(ctx))
+}
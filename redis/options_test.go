@@ -0,0 +1,62 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNewRedisClientWithOptions tests the pool and timeout options reach go-redis.
+func TestNewRedisClientWithOptions(t *testing.T) {
+	client, err := NewRedisClientWithOptions("localhost:6379", &Options{
+		PoolSize:     5,
+		MinIdleConns: 1,
+		DialTimeout:  time.Second,
+		ReadTimeout:  2 * time.Second,
+	})
+	require.NoError(t, err)
+	defer client.Close()
+	options := client.Client.(*redis.Client).Options()
+	assert.Equal(t, 5, options.PoolSize)
+	assert.Equal(t, 1, options.MinIdleConns)
+	assert.Equal(t, time.Second, options.DialTimeout)
+	assert.Equal(t, 2*time.Second, options.ReadTimeout)
+
+	client, err = NewRedisClientFromURL("redis://localhost:6379/0?pool_size=7")
+	require.NoError(t, err)
+	defer client.Close()
+	assert.Equal(t, 7, client.Client.(*redis.Client).Options().PoolSize)
+
+	// The client is closed when the server is not reachable
+	_, err = NewRedisClientWithOptions("localhost:1", &Options{DialTimeout: 100 * time.Millisecond, MaxRetries: -1})
+	assert.Error(t, err)
+}
+
+// TestClusterClient tests the multi-key helpers against a single-node cluster.
+func TestClusterClient(t *testing.T) {
+	client, err := NewClusterClient([]string{"localhost:6379"}, nil)
+	if err != nil {
+		t.Skipf("redis server does not support cluster mode: %v", err)
+	}
+	defer client.Close()
+	require.True(t, client.isCluster())
+
+	keys := []string{"test:cluster:a", "test:cluster:b", "test:cluster:c"}
+	require.NoError(t, client.Set(keys[0], "a", 10*time.Second))
+	require.NoError(t, client.Set(keys[1], "b", 10*time.Second))
+
+	values, err := client.MGet(keys...)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"a", "b", nil}, values)
+
+	n, err := client.Exists(keys...)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	n, err = client.Delete(keys...)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+}
@@ -0,0 +1,257 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// RATE_LIMIT_PREFIX is prepended to rate limiter keys: rate:<limiter name>:<key>.
+const RATE_LIMIT_PREFIX = "rate:"
+
+var (
+	ErrInvalidLimit = errors.New("redis: rate limit must be positive and period at least 1ms")
+	// ErrExceedsLimit is returned by AllowN when n is larger than the limit or bucket size, so it could never be allowed.
+	ErrExceedsLimit = errors.New("redis: n exceeds the rate limit")
+	// ErrInvalidN is returned by AllowN when n is less than 1.
+	ErrInvalidN = errors.New("redis: n must be at least 1")
+)
+
+// RateLimitResult is the outcome of a rate limit check.
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int64         // requests allowed per window, or the bucket size
+	Remaining  int64         // requests still allowed right now
+	RetryAfter time.Duration // when denied, how long until the request would be allowed; 0 when allowed
+	ResetAfter time.Duration // how long until the full quota is available again
+}
+
+// RateLimiter checks whether a request identified by key, e.g. an IP, user ID or API key, is allowed.
+// AllowN counts n requests at once, returns ErrInvalidN if n is less than 1 and ErrExceedsLimit if n is larger than the limit.
+type RateLimiter interface {
+	Allow(ctx context.Context, key string) (*RateLimitResult, error)
+	AllowN(ctx context.Context, key string, n int64) (*RateLimitResult, error)
+}
+
+// fixedWindowScript adds n to the window's counter and returns the count and the window's remaining time in ms.
+var fixedWindowScript = redis.NewScript(`
+local count = redis.call("INCRBY", KEYS[1], ARGV[1])
+local ttl = redis.call("PTTL", KEYS[1])
+if ttl < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	ttl = tonumber(ARGV[2])
+end
+return {count, ttl}
+`)
+
+// FixedWindowLimiter allows Limit requests per Window, counted with INCR. It is the cheapest algorithm,
+// but a client may send up to twice the limit around the boundary of two windows.
+// Denied requests are counted too, so clients that keep retrying stay limited until the window ends.
+type FixedWindowLimiter struct {
+	Redis  *RedisClient
+	Name   string
+	Limit  int64
+	Window time.Duration
+}
+
+// NewFixedWindowLimiter creates a FixedWindowLimiter. name separates the keys of different limiters, e.g. "sms".
+func NewFixedWindowLimiter(client *RedisClient, name string, limit int64, window time.Duration) *FixedWindowLimiter {
+	return &FixedWindowLimiter{Redis: client, Name: name, Limit: limit, Window: window}
+}
+
+func (l *FixedWindowLimiter) Allow(ctx context.Context, key string) (*RateLimitResult, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+func (l *FixedWindowLimiter) AllowN(ctx context.Context, key string, n int64) (*RateLimitResult, error) {
+	if l.Limit <= 0 || l.Window < time.Millisecond {
+		return nil, ErrInvalidLimit
+	}
+	if n < 1 {
+		return nil, ErrInvalidN
+	}
+	if n > l.Limit {
+		return nil, ErrExceedsLimit
+	}
+	values, err := fixedWindowScript.Run(ctx, l.Redis.Client, []string{rateLimitKey(l.Name, key)}, n, l.Window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	count, ttl := values[0], time.Duration(values[1])*time.Millisecond
+	result := &RateLimitResult{
+		Allowed:    count <= l.Limit,
+		Limit:      l.Limit,
+		Remaining:  max(l.Limit-count, 0),
+		ResetAfter: ttl,
+	}
+	if !result.Allowed {
+		result.RetryAfter = ttl
+	}
+	return result, nil
+}
+
+// slidingLogScript keeps one sorted set member per request, scored by its time in ms. Requests older
+// than the window are removed; a request is only recorded when allowed.
+// Returns allowed, the number of requests in the window, and the time in ms until the oldest one leaves it.
+var slidingLogScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+local n = tonumber(ARGV[4])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+local count = redis.call("ZCARD", KEYS[1])
+local allowed = 0
+if count + n <= limit then
+	for i = 1, n do
+		redis.call("ZADD", KEYS[1], now, ARGV[5] .. ":" .. i)
+	end
+	redis.call("PEXPIRE", KEYS[1], window)
+	count = count + n
+	allowed = 1
+end
+local wait = 0
+local oldest = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+if #oldest > 0 then
+	wait = tonumber(oldest[2]) + window - now
+end
+return {allowed, count, wait}
+`)
+
+// SlidingWindowLimiter allows Limit requests in any Window-long period, keeping a log of request times
+// in a sorted set. It is exact, but stores one member per allowed request, so it suits low limits such as
+// verification codes. Request times come from the application servers, whose clocks should be synchronized.
+type SlidingWindowLimiter struct {
+	Redis  *RedisClient
+	Name   string
+	Limit  int64
+	Window time.Duration
+}
+
+// NewSlidingWindowLimiter creates a SlidingWindowLimiter. name separates the keys of different limiters, e.g. "email".
+func NewSlidingWindowLimiter(client *RedisClient, name string, limit int64, window time.Duration) *SlidingWindowLimiter {
+	return &SlidingWindowLimiter{Redis: client, Name: name, Limit: limit, Window: window}
+}
+
+func (l *SlidingWindowLimiter) Allow(ctx context.Context, key string) (*RateLimitResult, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+func (l *SlidingWindowLimiter) AllowN(ctx context.Context, key string, n int64) (*RateLimitResult, error) {
+	if l.Limit <= 0 || l.Window < time.Millisecond {
+		return nil, ErrInvalidLimit
+	}
+	if n < 1 {
+		return nil, ErrInvalidN
+	}
+	if n > l.Limit {
+		return nil, ErrExceedsLimit
+	}
+	now := time.Now().UnixMilli()
+	values, err := slidingLogScript.Run(ctx, l.Redis.Client, []string{rateLimitKey(l.Name, key)},
+		now, l.Window.Milliseconds(), l.Limit, n, uuid.NewString()).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	allowed, count, wait := values[0] == 1, values[1], time.Duration(values[2])*time.Millisecond
+	result := &RateLimitResult{
+		Allowed:    allowed,
+		Limit:      l.Limit,
+		Remaining:  max(l.Limit-count, 0),
+		ResetAfter: wait,
+	}
+	if !allowed {
+		// The oldest request leaving the window frees a single slot, enough when n is 1.
+		result.RetryAfter = wait
+	}
+	return result, nil
+}
+
+// tokenBucketScript refills the bucket by the time passed since the last request, then takes n tokens if available.
+// The state is a hash of the tokens left and the time of the last update in ms; it expires once the bucket is full again.
+// Returns allowed, the whole tokens left, the ms until n tokens are available, and the ms until the bucket is full.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local n = tonumber(ARGV[4])
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+if now > ts then
+	tokens = math.min(burst, tokens + (now - ts) * rate)
+	ts = now
+end
+local allowed = 0
+local wait = 0
+if tokens >= n then
+	tokens = tokens - n
+	allowed = 1
+else
+	wait = math.ceil((n - tokens) / rate)
+end
+local reset = math.ceil((burst - tokens) / rate)
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", tostring(ts))
+redis.call("PEXPIRE", KEYS[1], math.max(reset, 1))
+return {allowed, math.floor(tokens), wait, reset}
+`)
+
+// TokenBucketLimiter allows bursts of up to Burst requests, refilled at Rate requests per Period,
+// e.g. Rate 10, Period time.Second, Burst 20. It smooths traffic without storing a log of requests.
+// Request times come from the application servers, whose clocks should be synchronized.
+type TokenBucketLimiter struct {
+	Redis  *RedisClient
+	Name   string
+	Rate   int64
+	Period time.Duration
+	Burst  int64 // 0 means Rate
+}
+
+// NewTokenBucketLimiter creates a TokenBucketLimiter. name separates the keys of different limiters, e.g. "api".
+func NewTokenBucketLimiter(client *RedisClient, name string, rate int64, period time.Duration, burst int64) *TokenBucketLimiter {
+	return &TokenBucketLimiter{Redis: client, Name: name, Rate: rate, Period: period, Burst: burst}
+}
+
+func (l *TokenBucketLimiter) Allow(ctx context.Context, key string) (*RateLimitResult, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+func (l *TokenBucketLimiter) AllowN(ctx context.Context, key string, n int64) (*RateLimitResult, error) {
+	if l.Rate <= 0 || l.Period < time.Millisecond {
+		return nil, ErrInvalidLimit
+	}
+	burst := l.Burst
+	if burst <= 0 {
+		burst = l.Rate
+	}
+	if n < 1 {
+		return nil, ErrInvalidN
+	}
+	if n > burst {
+		return nil, ErrExceedsLimit
+	}
+	// Tokens added per millisecond
+	rate := float64(l.Rate) / float64(l.Period.Milliseconds())
+	values, err := tokenBucketScript.Run(ctx, l.Redis.Client, []string{rateLimitKey(l.Name, key)},
+		strconv.FormatFloat(rate, 'g', -1, 64), burst, time.Now().UnixMilli(), n).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	result := &RateLimitResult{
+		Allowed:    values[0] == 1,
+		Limit:      burst,
+		Remaining:  values[1],
+		ResetAfter: time.Duration(values[3]) * time.Millisecond,
+	}
+	if !result.Allowed {
+		result.RetryAfter = time.Duration(values[2]) * time.Millisecond
+	}
+	return result, nil
+}
+
+func rateLimitKey(name, key string) string {
+	return RATE_LIMIT_PREFIX + name + ":" + key
+}
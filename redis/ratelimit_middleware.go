@@ -0,0 +1,109 @@
+package redis
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeyFunc returns the rate limit key of a request. An empty key skips rate limiting for the request.
+type KeyFunc func(r *http.Request) string
+
+// KeyByIP keys requests by the client IP from RemoteAddr.
+// Behind a reverse proxy, use KeyByForwardedIP or set RemoteAddr from a trusted header first.
+func KeyByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// KeyByForwardedIP keys requests by the first address in X-Forwarded-For, falling back to RemoteAddr.
+// Only use it behind a proxy that overwrites the header, as clients can set it to anything.
+func KeyByForwardedIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		ip, _, _ := strings.Cut(forwarded, ",")
+		return strings.TrimSpace(ip)
+	}
+	return KeyByIP(r)
+}
+
+// KeyByHeader keys requests by a header, e.g. "X-API-Key".
+func KeyByHeader(header string) KeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(header)
+	}
+}
+
+// KeyByQuery keys requests by a query parameter, e.g. "ak", the app key of signature-verified requests.
+func KeyByQuery(param string) KeyFunc {
+	return func(r *http.Request) string {
+		return r.URL.Query().Get(param)
+	}
+}
+
+// RateLimitMiddlewareOptions configures RateLimitMiddleware.
+type RateLimitMiddlewareOptions struct {
+	// Key identifies the client, e.g. KeyByIP, KeyByHeader("X-API-Key"), or a func reading the user ID
+	// put into the request context by an authentication middleware. nil means KeyByIP.
+	Key KeyFunc
+	// Prefix is prepended to the key, e.g. "ip:" or "user:", when several middlewares share a limiter.
+	Prefix string
+	// LimitedHandler writes the response when the limit is exceeded; nil means 429 Too Many Requests.
+	LimitedHandler func(w http.ResponseWriter, r *http.Request, result *RateLimitResult)
+	// ErrorHandler is called when Redis fails. nil means the request is let through, so a Redis outage
+	// does not take the API down; set it to respond with an error instead.
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// RateLimitMiddleware returns a net/http middleware that checks each request against limiter.
+// It sets the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers, and Retry-After when limited.
+func RateLimitMiddleware(limiter RateLimiter, options RateLimitMiddlewareOptions) func(http.Handler) http.Handler {
+	keyFunc := options.Key
+	if keyFunc == nil {
+		keyFunc = KeyByIP
+	}
+	limitedHandler := options.LimitedHandler
+	if limitedHandler == nil {
+		limitedHandler = func(w http.ResponseWriter, r *http.Request, result *RateLimitResult) {
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := keyFunc(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			result, err := limiter.Allow(r.Context(), options.Prefix+key)
+			if err != nil {
+				if options.ErrorHandler != nil {
+					options.ErrorHandler(w, r, err)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			header := w.Header()
+			header.Set("RateLimit-Limit", strconv.FormatInt(result.Limit, 10))
+			header.Set("RateLimit-Remaining", strconv.FormatInt(result.Remaining, 10))
+			header.Set("RateLimit-Reset", seconds(result.ResetAfter))
+			if !result.Allowed {
+				header.Set("Retry-After", seconds(result.RetryAfter))
+				limitedHandler(w, r, result)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// seconds rounds d up to whole seconds, as used by the Retry-After and RateLimit-Reset headers.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
@@ -0,0 +1,136 @@
+package redis
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiters(t *testing.T) {
+	ctx := context.Background()
+	limiters := map[string]RateLimiter{
+		"fixed":   NewFixedWindowLimiter(r, "test:fixed", 3, time.Second),
+		"sliding": NewSlidingWindowLimiter(r, "test:sliding", 3, time.Second),
+		"bucket":  NewTokenBucketLimiter(r, "test:bucket", 3, time.Second, 0),
+	}
+	for name, limiter := range limiters {
+		t.Run(name, func(t *testing.T) {
+			defer r.Delete(rateLimitKey("test:"+name, "client"))
+			for i := int64(1); i <= 3; i++ {
+				result, err := limiter.Allow(ctx, "client")
+				require.NoError(t, err)
+				assert.True(t, result.Allowed)
+				assert.Equal(t, int64(3), result.Limit)
+				assert.Equal(t, 3-i, result.Remaining)
+				assert.Zero(t, result.RetryAfter)
+			}
+			result, err := limiter.Allow(ctx, "client")
+			require.NoError(t, err)
+			assert.False(t, result.Allowed)
+			assert.Zero(t, result.Remaining)
+			assert.True(t, result.RetryAfter > 0 && result.RetryAfter <= time.Second, result.RetryAfter)
+
+			retryAfter := result.RetryAfter
+
+			// Other keys have their own quota
+			result, err = limiter.Allow(ctx, "other")
+			require.NoError(t, err)
+			assert.True(t, result.Allowed)
+			r.Delete(rateLimitKey("test:"+name, "other"))
+
+			// Allowed again after waiting
+			time.Sleep(retryAfter + 100*time.Millisecond)
+			result, err = limiter.Allow(ctx, "client")
+			require.NoError(t, err)
+			assert.True(t, result.Allowed)
+		})
+	}
+
+	// Invalid settings and requests that could never be allowed are rejected before reaching Redis
+	for _, limiter := range []RateLimiter{
+		NewFixedWindowLimiter(r, "test:invalid", 0, time.Second),
+		NewFixedWindowLimiter(r, "test:invalid", 3, time.Microsecond),
+		NewSlidingWindowLimiter(r, "test:invalid", 3, 999*time.Microsecond),
+		NewTokenBucketLimiter(r, "test:invalid", 3, time.Microsecond, 0),
+	} {
+		_, err := limiter.Allow(ctx, "client")
+		assert.Equal(t, ErrInvalidLimit, err)
+	}
+	for name, limiter := range limiters {
+		_, err := limiter.AllowN(ctx, "client", 4)
+		assert.Equal(t, ErrExceedsLimit, err, name)
+		for _, n := range []int64{0, -1} {
+			_, err = limiter.AllowN(ctx, "client", n)
+			assert.Equal(t, ErrInvalidN, err, name)
+		}
+	}
+}
+
+func TestTokenBucketLimiter(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewTokenBucketLimiter(r, "test:burst", 10, time.Second, 5)
+	defer r.Delete(rateLimitKey("test:burst", "client"))
+
+	// The whole burst at once, then refilled at one token per 100ms
+	result, err := limiter.AllowN(ctx, "client", 5)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Zero(t, result.Remaining)
+	assert.InDelta(t, 500*time.Millisecond, result.ResetAfter, float64(20*time.Millisecond))
+
+	result, err = limiter.AllowN(ctx, "client", 2)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.InDelta(t, 200*time.Millisecond, result.RetryAfter, float64(20*time.Millisecond))
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	limiter := NewFixedWindowLimiter(r, "test:middleware", 2, time.Minute)
+	defer r.Delete(rateLimitKey("test:middleware", "key:abc"), rateLimitKey("test:middleware", "key:def"))
+	handler := RateLimitMiddleware(limiter, RateLimitMiddlewareOptions{
+		Key:    KeyByHeader("X-API-Key"),
+		Prefix: "key:",
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	request := func(apiKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("abc")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "60", w.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, http.StatusNoContent, request("abc").Code)
+
+	w = request("abc")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	// Another key, and requests without a key, are not limited
+	assert.Equal(t, http.StatusNoContent, request("def").Code)
+	w = request("")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+
+	// KeyByIP
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "203.0.113.7:52100"
+	assert.Equal(t, "203.0.113.7", KeyByIP(req))
+	req.Header.Set("X-Forwarded-For", "198.51.100.1, 203.0.113.7")
+	assert.Equal(t, "198.51.100.1", KeyByForwardedIP(req))
+}
@@ -1,8 +1,12 @@
+// Package redis wraps go-redis for single node, Sentinel and Cluster deployments.
+// Cache is a typed cache with stampede protection.
+// Locker obtains distributed locks with fencing tokens.
+// The rate limiters come with a net/http middleware.
 package redis
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -11,28 +15,21 @@ import (
 // go-redis v9 usage: https://redis.io/docs/clients/go/
 
 // RedisClient is a wrapper for the go-redis client.
-// It holds a long-lived client that manages a connection pool.
+// It holds a long-lived client that manages a connection pool. Client is a *redis.Client for a single server,
+// a failover client for Sentinel or a *redis.ClusterClient, see NewSentinelClient and NewClusterClient.
 type RedisClient struct {
-	Client *redis.Client
+	Client redis.UniversalClient
 }
 
+// ctx is used by the methods without a Context suffix. In request handlers prefer the
+// XxxContext variants, so request cancellation, deadlines and tracing reach Redis.
 var ctx = context.Background()
 
 // NewRedisClient creates a new RedisClient.
 // It establishes a connection and pings the server to ensure it's alive.
+// Use NewRedisClientWithOptions for ACL usernames, TLS, pool sizing and timeouts.
 func NewRedisClient(addr string, password string, db int) (*RedisClient, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:     addr,
-		Password: password,
-		DB:       db,
-	})
-
-	// Test the connection
-	if _, err := client.Ping(ctx).Result(); err != nil {
-		return nil, fmt.Errorf("redis connection test failed: %w", err)
-	}
-
-	return &RedisClient{Client: client}, nil
+	return NewRedisClientWithOptions(addr, &Options{Password: password, DB: db})
 }
 
 // Close closes the underlying redis client and release resources.
@@ -40,81 +37,208 @@ func (r *RedisClient) Close() error {
 	return r.Client.Close()
 }
 
-// Keys finds all keys matching the given pattern.
-// Warning: KEYS can be slow on a large database.
+// Keys finds all keys matching the given pattern. It iterates with SCAN, so Redis is not blocked,
+// but all keys are held in memory; use ScanKeys to process a large keyspace as it is scanned.
 func (r *RedisClient) Keys(pattern string) ([]string, error) {
-	return r.Client.Keys(ctx, pattern).Result()
+	return r.KeysContext(ctx, pattern)
+}
+
+// KeysContext is like Keys but uses the given context.
+func (r *RedisClient) KeysContext(ctx context.Context, pattern string) ([]string, error) {
+	seen := make(map[string]struct{})
+	keys := []string{}
+	for key, err := range r.ScanKeys(ctx, pattern, 0) {
+		if err != nil {
+			return nil, err
+		}
+		// SCAN may return a key more than once
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
 }
 
 // Set sets a key-value pair. A duration of 0 means no expiration.
 func (r *RedisClient) Set(key string, value interface{}, duration time.Duration) error {
+	return r.SetContext(ctx, key, value, duration)
+}
+
+// SetContext is like Set but uses the given context.
+func (r *RedisClient) SetContext(ctx context.Context, key string, value interface{}, duration time.Duration) error {
 	return r.Client.Set(ctx, key, value, duration).Err()
 }
 
 // Get retrieves a value by key. Returns redis.Nil error if key does not exist.
 func (r *RedisClient) Get(key string) ([]byte, error) {
+	return r.GetContext(ctx, key)
+}
+
+// GetContext is like Get but uses the given context.
+func (r *RedisClient) GetContext(ctx context.Context, key string) ([]byte, error) {
 	return r.Client.Get(ctx, key).Bytes()
 }
 
-// MGet retrieves multiple values by keys.
+// MGet retrieves multiple values by keys. Missing keys are nil.
 func (r *RedisClient) MGet(keys ...string) ([]interface{}, error) {
-	return r.Client.MGet(ctx, keys...).Result()
+	return r.MGetContext(ctx, keys...)
+}
+
+// MGetContext is like MGet but uses the given context.
+func (r *RedisClient) MGetContext(ctx context.Context, keys ...string) ([]interface{}, error) {
+	if !r.isCluster() || len(keys) < 2 {
+		return r.Client.MGet(ctx, keys...).Result()
+	}
+	// In a cluster the keys may live in different slots, so GET them one by one in a pipeline,
+	// which go-redis splits per node. Missing keys are nil, as with MGET.
+	pl := r.Client.Pipeline()
+	cmds := make([]*redis.StringCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pl.Get(ctx, key)
+	}
+	// Exec only returns the first error, which may be redis.Nil of a missing key, so check every command.
+	pl.Exec(ctx)
+	values := make([]interface{}, len(keys))
+	for i, cmd := range cmds {
+		value, err := cmd.Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
 }
 
 // SetNX sets a key-value pair only if the key does not exist.
 func (r *RedisClient) SetNX(key string, value interface{}, duration time.Duration) (bool, error) {
+	return r.SetNXContext(ctx, key, value, duration)
+}
+
+// SetNXContext is like SetNX but uses the given context.
+func (r *RedisClient) SetNXContext(ctx context.Context, key string, value interface{}, duration time.Duration) (bool, error) {
 	return r.Client.SetNX(ctx, key, value, duration).Result()
 }
 
 // SetXX sets a key-value pair only if the key already exists.
 func (r *RedisClient) SetXX(key string, value interface{}, duration time.Duration) (bool, error) {
+	return r.SetXXContext(ctx, key, value, duration)
+}
+
+// SetXXContext is like SetXX but uses the given context.
+func (r *RedisClient) SetXXContext(ctx context.Context, key string, value interface{}, duration time.Duration) (bool, error) {
 	return r.Client.SetXX(ctx, key, value, duration).Result()
 }
 
 // Exists checks if one or more keys exist.
 func (r *RedisClient) Exists(key ...string) (int64, error) {
-	return r.Client.Exists(ctx, key...).Result()
+	return r.ExistsContext(ctx, key...)
+}
+
+// ExistsContext is like Exists but uses the given context.
+func (r *RedisClient) ExistsContext(ctx context.Context, key ...string) (int64, error) {
+	if !r.isCluster() || len(key) < 2 {
+		return r.Client.Exists(ctx, key...).Result()
+	}
+	return r.sumPerKey(ctx, key, func(pl redis.Pipeliner, key string) *redis.IntCmd {
+		return pl.Exists(ctx, key)
+	})
 }
 
 // GetSet sets a new value for a key and returns the old value.
 func (r *RedisClient) GetSet(key string, value interface{}) ([]byte, error) {
+	return r.GetSetContext(ctx, key, value)
+}
+
+// GetSetContext is like GetSet but uses the given context.
+func (r *RedisClient) GetSetContext(ctx context.Context, key string, value interface{}) ([]byte, error) {
 	return r.Client.GetSet(ctx, key, value).Bytes()
 }
 
 // Delete deletes one or more keys. Returns the number of keys that were removed.
 func (r *RedisClient) Delete(keys ...string) (int64, error) {
-	return r.Client.Del(ctx, keys...).Result()
+	return r.DeleteContext(ctx, keys...)
+}
+
+// DeleteContext is like Delete but uses the given context.
+func (r *RedisClient) DeleteContext(ctx context.Context, keys ...string) (int64, error) {
+	if !r.isCluster() || len(keys) < 2 {
+		return r.Client.Del(ctx, keys...).Result()
+	}
+	return r.sumPerKey(ctx, keys, func(pl redis.Pipeliner, key string) *redis.IntCmd {
+		return pl.Del(ctx, key)
+	})
+}
+
+// sumPerKey runs a single-key command for each key in a pipeline and sums the results.
+// It is used in a cluster instead of one command with keys in different slots.
+func (r *RedisClient) sumPerKey(ctx context.Context, keys []string, command func(pl redis.Pipeliner, key string) *redis.IntCmd) (int64, error) {
+	pl := r.Client.Pipeline()
+	cmds := make([]*redis.IntCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = command(pl, key)
+	}
+	if _, err := pl.Exec(ctx); err != nil {
+		return 0, err
+	}
+	var n int64
+	for _, cmd := range cmds {
+		n += cmd.Val()
+	}
+	return n, nil
 }
 
 // TTL returns the remaining time to live of a key.
 func (r *RedisClient) TTL(key string) (time.Duration, error) {
+	return r.TTLContext(ctx, key)
+}
+
+// TTLContext is like TTL but uses the given context.
+func (r *RedisClient) TTLContext(ctx context.Context, key string) (time.Duration, error) {
 	return r.Client.TTL(ctx, key).Result()
 }
 
 // Expire sets a new expiration for a key.
 func (r *RedisClient) Expire(key string, duration time.Duration) (bool, error) {
+	return r.ExpireContext(ctx, key, duration)
+}
+
+// ExpireContext is like Expire but uses the given context.
+func (r *RedisClient) ExpireContext(ctx context.Context, key string, duration time.Duration) (bool, error) {
 	return r.Client.Expire(ctx, key, duration).Result()
 }
 
-// LikeDeletes deletes keys matching a pattern. Warning: KEYS can be slow in production.
+// LikeDeletes deletes keys containing key, i.e. matching *key*, with SCAN and batched UNLINK.
+// See DeleteMatching for other patterns and progress reporting.
 func (r *RedisClient) LikeDeletes(key string) (int64, error) {
-	keys, err := r.Client.Keys(ctx, "*"+key+"*").Result()
-	if err != nil {
-		return 0, err
-	}
-	if len(keys) == 0 {
-		return 0, nil
-	}
-	return r.Delete(keys...)
+	return r.LikeDeletesContext(ctx, key)
+}
+
+// LikeDeletesContext is like LikeDeletes but uses the given context.
+func (r *RedisClient) LikeDeletesContext(ctx context.Context, key string) (int64, error) {
+	return r.DeleteMatching(ctx, "*"+key+"*", DeleteOptions{})
 }
 
 // RPush appends one or more values to a list.
 func (r *RedisClient) RPush(key string, values ...interface{}) (int64, error) {
+	return r.RPushContext(ctx, key, values...)
+}
+
+// RPushContext is like RPush but uses the given context.
+func (r *RedisClient) RPushContext(ctx context.Context, key string, values ...interface{}) (int64, error) {
 	return r.Client.RPush(ctx, key, values...).Result()
 }
 
 // RPushX appends a value to a list, only if the list exists.
 func (r *RedisClient) RPushX(key string, value interface{}) (int64, error) {
+	return r.RPushXContext(ctx, key, value)
+}
+
+// RPushXContext is like RPushX but uses the given context.
+func (r *RedisClient) RPushXContext(ctx context.Context, key string, value interface{}) (int64, error) {
 	return r.Client.RPushX(ctx, key, value).Result()
 }
 
@@ -123,37 +247,104 @@ type Z = redis.Z
 
 // ZAdd adds one or more members to a sorted set.
 func (r *RedisClient) ZAdd(key string, members ...Z) (int64, error) {
+	return r.ZAddContext(ctx, key, members...)
+}
+
+// ZAddContext is like ZAdd but uses the given context.
+func (r *RedisClient) ZAddContext(ctx context.Context, key string, members ...Z) (int64, error) {
 	return r.Client.ZAdd(ctx, key, members...).Result()
 }
 
 // ZRange returns a range of members from a sorted set, by index.
 func (r *RedisClient) ZRange(key string, start, stop int64) ([]string, error) {
+	return r.ZRangeContext(ctx, key, start, stop)
+}
+
+// ZRangeContext is like ZRange but uses the given context.
+func (r *RedisClient) ZRangeContext(ctx context.Context, key string, start, stop int64) ([]string, error) {
 	return r.Client.ZRange(ctx, key, start, stop).Result()
 }
 
 // ZRevRange returns a range of members from a sorted set, by index, in reverse order.
 func (r *RedisClient) ZRevRange(key string, start, stop int64) ([]string, error) {
+	return r.ZRevRangeContext(ctx, key, start, stop)
+}
+
+// ZRevRangeContext is like ZRevRange but uses the given context.
+func (r *RedisClient) ZRevRangeContext(ctx context.Context, key string, start, stop int64) ([]string, error) {
 	return r.Client.ZRevRange(ctx, key, start, stop).Result()
 }
 
+// ZRangeByScore returns members of a sorted set with scores between min and max.
+// min and max may be "-inf" and "+inf".
+func (r *RedisClient) ZRangeByScore(key string, min, max string) ([]string, error) {
+	return r.ZRangeByScoreContext(ctx, key, min, max)
+}
+
+// ZRangeByScoreContext is like ZRangeByScore but uses the given context.
+func (r *RedisClient) ZRangeByScoreContext(ctx context.Context, key string, min, max string) ([]string, error) {
+	return r.Client.ZRangeByScore(ctx, key, &redis.ZRangeBy{Min: min, Max: max}).Result()
+}
+
+// ZRem removes one or more members from a sorted set.
+func (r *RedisClient) ZRem(key string, members ...interface{}) (int64, error) {
+	return r.ZRemContext(ctx, key, members...)
+}
+
+// ZRemContext is like ZRem but uses the given context.
+func (r *RedisClient) ZRemContext(ctx context.Context, key string, members ...interface{}) (int64, error) {
+	return r.Client.ZRem(ctx, key, members...).Result()
+}
+
+// ZRemRangeByScore removes all members of a sorted set with scores between min and max.
+func (r *RedisClient) ZRemRangeByScore(key string, min, max string) (int64, error) {
+	return r.ZRemRangeByScoreContext(ctx, key, min, max)
+}
+
+// ZRemRangeByScoreContext is like ZRemRangeByScore but uses the given context.
+func (r *RedisClient) ZRemRangeByScoreContext(ctx context.Context, key string, min, max string) (int64, error) {
+	return r.Client.ZRemRangeByScore(ctx, key, min, max).Result()
+}
+
 // HMSet sets multiple hash fields to multiple values.
 func (r *RedisClient) HMSet(key string, fields map[string]interface{}) (bool, error) {
+	return r.HMSetContext(ctx, key, fields)
+}
+
+// HMSetContext is like HMSet but uses the given context.
+func (r *RedisClient) HMSetContext(ctx context.Context, key string, fields map[string]interface{}) (bool, error) {
 	// Note: HMSet is deprecated in Redis 4.0.0. Consider using HSet with multiple field-value pairs.
 	return r.Client.HMSet(ctx, key, fields).Result()
 }
 
-// MSet sets multiple key-value pairs.
+// MSet sets multiple key-value pairs. In a cluster all keys must hash to the same slot, e.g. share a {hash tag}.
 func (r *RedisClient) MSet(pairs ...interface{}) (string, error) {
+	return r.MSetContext(ctx, pairs...)
+}
+
+// MSetContext is like MSet but uses the given context.
+func (r *RedisClient) MSetContext(ctx context.Context, pairs ...interface{}) (string, error) {
 	return r.Client.MSet(ctx, pairs...).Result()
 }
 
 // MSetNX sets multiple key-value pairs, only if none of the keys exist.
+// In a cluster all keys must hash to the same slot, e.g. share a {hash tag}.
 func (r *RedisClient) MSetNX(pairs ...interface{}) (bool, error) {
+	return r.MSetNXContext(ctx, pairs...)
+}
+
+// MSetNXContext is like MSetNX but uses the given context.
+func (r *RedisClient) MSetNXContext(ctx context.Context, pairs ...interface{}) (bool, error) {
 	return r.Client.MSetNX(ctx, pairs...).Result()
 }
 
 // MExpire sets an expiration for multiple keys using a pipeline.
 func (r *RedisClient) MExpire(keys []string, duration time.Duration) error {
+	return r.MExpireContext(ctx, keys, duration)
+}
+
+// MExpireContext is like MExpire but uses the given context.
+func (r *RedisClient) MExpireContext(ctx context.Context, keys []string, duration time.Duration) error {
 	pl := r.Client.Pipeline()
 	for _, key := range keys {
 		pl.Expire(ctx, key, duration)
@@ -164,16 +355,32 @@ func (r *RedisClient) MExpire(keys []string, duration time.Duration) error {
 
 // PFAdd adds elements to a HyperLogLog.
 func (r *RedisClient) PFAdd(key string, els ...interface{}) (int64, error) {
+	return r.PFAddContext(ctx, key, els...)
+}
+
+// PFAddContext is like PFAdd but uses the given context.
+func (r *RedisClient) PFAddContext(ctx context.Context, key string, els ...interface{}) (int64, error) {
 	return r.Client.PFAdd(ctx, key, els...).Result()
 }
 
 // PFCount returns the approximate cardinality of the set observed by the HyperLogLog.
+// With several keys it counts their union; in a cluster they must hash to the same slot.
 func (r *RedisClient) PFCount(keys ...string) (int64, error) {
+	return r.PFCountContext(ctx, keys...)
+}
+
+// PFCountContext is like PFCount but uses the given context.
+func (r *RedisClient) PFCountContext(ctx context.Context, keys ...string) (int64, error) {
 	return r.Client.PFCount(ctx, keys...).Result()
 }
 
 // MPFCount counts the cardinality of multiple HyperLogLogs using a pipeline.
 func (r *RedisClient) MPFCount(keys []string) (map[string]int64, error) {
+	return r.MPFCountContext(ctx, keys)
+}
+
+// MPFCountContext is like MPFCount but uses the given context.
+func (r *RedisClient) MPFCountContext(ctx context.Context, keys []string) (map[string]int64, error) {
 	resultMap := make(map[string]int64)
 	pl := r.Client.Pipeline()
 	cmds := make([]*redis.IntCmd, len(keys))
@@ -200,5 +407,10 @@ func (r *RedisClient) MPFCount(keys []string) (map[string]int64, error) {
 
 // Incr increments the integer value of a key by one.
 func (r *RedisClient) Incr(key string) (int64, error) {
+	return r.IncrContext(ctx, key)
+}
+
+// IncrContext is like Incr but uses the given context.
+func (r *RedisClient) IncrContext(ctx context.Context, key string) (int64, error) {
 	return r.Client.Incr(ctx, key).Result()
 }
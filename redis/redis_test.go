@@ -1,6 +1,7 @@
 package redis
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"testing"
@@ -237,3 +238,33 @@ func TestLikeDeletes(t *testing.T) {
 	// Cleanup
 	_, _ = r.Delete(keys...)
 }
+
+// TestContextVariants tests that the XxxContext methods use the given context.
+func TestContextVariants(t *testing.T) {
+	key := "test:context"
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	require.NoError(t, r.SetContext(ctx, key, "val", 10*time.Second))
+	val, err := r.GetContext(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, "val", string(val))
+
+	n, err := r.ZAddContext(ctx, key+":z", Z{Score: 1, Member: "a"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	require.NoError(t, r.MExpireContext(ctx, []string{key, key + ":z"}, 10*time.Second))
+
+	// A canceled context fails without reaching Redis
+	canceled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	_, err = r.GetContext(canceled, key)
+	assert.ErrorIs(t, err, context.Canceled)
+	err = r.MExpireContext(canceled, []string{key}, time.Second)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// Cleanup
+	n, err = r.DeleteContext(ctx, key, key+":z")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+}
@@ -0,0 +1,145 @@
+package redis
+
+import (
+	"context"
+	"iter"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// DEFAULT_SCAN_COUNT is the COUNT hint of each SCAN call: roughly how many keys Redis examines per call.
+	DEFAULT_SCAN_COUNT = 1000
+	// DEFAULT_DELETE_BATCH is how many keys are unlinked per pipeline.
+	DEFAULT_DELETE_BATCH = 500
+)
+
+// DeleteOptions configures DeleteMatching.
+type DeleteOptions struct {
+	// Count is the COUNT hint of each SCAN call; 0 means DEFAULT_SCAN_COUNT.
+	Count int64
+	// BatchSize is how many keys are unlinked per pipeline; 0 means DEFAULT_DELETE_BATCH.
+	BatchSize int
+	// Progress, if set, is called after each batch with the total keys scanned and deleted so far.
+	Progress func(scanned, deleted int64)
+}
+
+// ScanKeys returns an iterator over the keys matching pattern, using SCAN instead of the blocking KEYS.
+// count is the COUNT hint of each SCAN call; 0 means DEFAULT_SCAN_COUNT. In a cluster every master is scanned.
+// SCAN may return a key more than once, and keys added or removed during the iteration may or may not be returned.
+// The iteration stops at the first error, which is yielded with an empty key.
+func (r *RedisClient) ScanKeys(ctx context.Context, pattern string, count int64) iter.Seq2[string, error] {
+	if count <= 0 {
+		count = DEFAULT_SCAN_COUNT
+	}
+	return func(yield func(string, error) bool) {
+		nodes, err := r.masters(ctx)
+		if err != nil {
+			yield("", err)
+			return
+		}
+		for _, node := range nodes {
+			it := node.Scan(ctx, 0, pattern, count).Iterator()
+			for it.Next(ctx) {
+				if !yield(it.Val(), nil) {
+					return
+				}
+			}
+			if err := it.Err(); err != nil {
+				yield("", err)
+				return
+			}
+		}
+	}
+}
+
+// DeleteMatching deletes the keys matching pattern without blocking Redis: keys are found with SCAN
+// and removed in batches with UNLINK, which frees the memory in the background. Returns the number of keys deleted.
+func (r *RedisClient) DeleteMatching(ctx context.Context, pattern string, options DeleteOptions) (int64, error) {
+	if options.Count <= 0 {
+		options.Count = DEFAULT_SCAN_COUNT
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = DEFAULT_DELETE_BATCH
+	}
+	nodes, err := r.masters(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var scanned, deleted int64
+	for _, node := range nodes {
+		batch := make([]string, 0, options.BatchSize)
+		flush := func() error {
+			n, err := r.unlink(ctx, node, batch)
+			if err != nil {
+				return err
+			}
+			deleted += n
+			batch = batch[:0]
+			if options.Progress != nil {
+				options.Progress(scanned, deleted)
+			}
+			return nil
+		}
+		it := node.Scan(ctx, 0, pattern, options.Count).Iterator()
+		for it.Next(ctx) {
+			scanned++
+			batch = append(batch, it.Val())
+			if len(batch) == options.BatchSize {
+				if err := flush(); err != nil {
+					return deleted, err
+				}
+			}
+		}
+		if err := it.Err(); err != nil {
+			return deleted, err
+		}
+		if len(batch) > 0 {
+			if err := flush(); err != nil {
+				return deleted, err
+			}
+		}
+	}
+	return deleted, nil
+}
+
+// unlink removes keys found on node in one pipeline. In a cluster the keys may be in different slots,
+// so each key gets its own UNLINK; otherwise a single UNLINK removes the whole batch.
+func (r *RedisClient) unlink(ctx context.Context, node redis.Cmdable, keys []string) (int64, error) {
+	if !r.isCluster() {
+		return node.Unlink(ctx, keys...).Result()
+	}
+	pl := node.Pipeline()
+	cmds := make([]*redis.IntCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pl.Unlink(ctx, key)
+	}
+	if _, err := pl.Exec(ctx); err != nil {
+		return 0, err
+	}
+	var n int64
+	for _, cmd := range cmds {
+		n += cmd.Val()
+	}
+	return n, nil
+}
+
+// masters returns the nodes holding the keys: every master in a cluster, otherwise the client itself.
+func (r *RedisClient) masters(ctx context.Context) ([]redis.Cmdable, error) {
+	cluster, ok := r.Client.(*redis.ClusterClient)
+	if !ok {
+		return []redis.Cmdable{r.Client}, nil
+	}
+	var (
+		mu    sync.Mutex
+		nodes []redis.Cmdable
+	)
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+		mu.Lock()
+		defer mu.Unlock()
+		nodes = append(nodes, client)
+		return nil
+	})
+	return nodes, err
+}
@@ -0,0 +1,94 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setScanKeys(t *testing.T, client *RedisClient, prefix string, n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%s:%d", prefix, i)
+		require.NoError(t, client.Set(keys[i], i, 10*time.Second))
+	}
+	return keys
+}
+
+func TestScanKeys(t *testing.T) {
+	ctx := context.Background()
+	keys := setScanKeys(t, r, "test:scan", 5)
+	defer r.Delete(keys...)
+
+	var scanned []string
+	for key, err := range r.ScanKeys(ctx, "test:scan:*", 2) {
+		require.NoError(t, err)
+		scanned = append(scanned, key)
+	}
+	sort.Strings(scanned)
+	assert.Equal(t, keys, scanned)
+
+	// Stop early
+	n := 0
+	for _, err := range r.ScanKeys(ctx, "test:scan:*", 2) {
+		require.NoError(t, err)
+		n++
+		if n == 2 {
+			break
+		}
+	}
+	assert.Equal(t, 2, n)
+
+	found, err := r.Keys("test:scan:*")
+	require.NoError(t, err)
+	sort.Strings(found)
+	assert.Equal(t, keys, found)
+
+	found, err = r.Keys("test:scan:none:*")
+	require.NoError(t, err)
+	assert.Empty(t, found)
+}
+
+func TestDeleteMatching(t *testing.T) {
+	ctx := context.Background()
+	keys := setScanKeys(t, r, "test:deletematching", 5)
+	other := setScanKeys(t, r, "test:keep", 1)
+	defer r.Delete(other...)
+
+	var progress [][2]int64
+	deleted, err := r.DeleteMatching(ctx, "test:deletematching:*", DeleteOptions{
+		BatchSize: 2,
+		Progress: func(scanned, deleted int64) {
+			progress = append(progress, [2]int64{scanned, deleted})
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), deleted)
+	assert.Equal(t, [][2]int64{{2, 2}, {4, 4}, {5, 5}}, progress)
+
+	n, err := r.Exists(append(keys, other...)...)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	// Canceled context
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = r.DeleteMatching(canceled, "test:deletematching:*", DeleteOptions{})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// Cluster: every key gets its own UNLINK
+	client, err := NewClusterClient([]string{"localhost:6379"}, nil)
+	if err != nil {
+		t.Skipf("redis server does not support cluster mode: %v", err)
+	}
+	defer client.Close()
+	setScanKeys(t, client, "test:deletematching", 3)
+	deleted, err = client.DeleteMatching(ctx, "test:deletematching:*", DeleteOptions{BatchSize: 2})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+}
@@ -0,0 +1,150 @@
+package refresh_token
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net"
+	"strings"
+)
+
+// 计算Subnet时使用的前缀长度
+const (
+	IPV4_SUBNET_BITS = 24
+	IPV6_SUBNET_BITS = 48
+)
+
+// Fingerprint 设备指纹，用于判断refresh token是否在同一设备上使用
+type Fingerprint struct {
+	IP        string
+	Platform  string // 如 macOS、iOS、Android、Windows
+	Browser   string // 如 Chrome、Safari、Firefox
+	Subnet    string // IP所在的网段，ipv4为/24，ipv6为/48
+	ClientKey string // 客户端首次启动时生成并保存的随机key的sha256，可为空
+}
+
+// NewFingerprint 根据请求的User-Agent、ip和客户端key(可为空)生成设备指纹
+func NewFingerprint(userAgent, ip, clientKey string) Fingerprint {
+	platform, browser := ParseUserAgent(userAgent)
+	return Fingerprint{
+		IP:        ip,
+		Platform:  platform,
+		Browser:   browser,
+		Subnet:    IPSubnet(ip),
+		ClientKey: hashClientKey(clientKey),
+	}
+}
+
+// IsZero 是否为空的指纹，空指纹不做风险检查
+func (f Fingerprint) IsZero() bool {
+	return f == Fingerprint{}
+}
+
+// SameDevice 是否是同一设备：双方都有ClientKey时只比较ClientKey，ip变化不影响；
+// 否则要求平台和网段相同，双方都有浏览器信息时浏览器也要相同
+func (f Fingerprint) SameDevice(other Fingerprint) bool {
+	if f.ClientKey != "" && other.ClientKey != "" {
+		return subtle.ConstantTimeCompare([]byte(f.ClientKey), []byte(other.ClientKey)) == 1
+	}
+	if f.Platform != other.Platform || f.Subnet != other.Subnet {
+		return false
+	}
+	return f.Browser == "" || other.Browser == "" || f.Browser == other.Browser
+}
+
+// Fingerprint token登录时的设备指纹，老数据没有Subnet时由IP计算
+func (token *RefreshToken) Fingerprint() Fingerprint {
+	subnet := token.Subnet
+	if subnet == "" {
+		subnet = IPSubnet(token.IP)
+	}
+	return Fingerprint{
+		IP:        token.IP,
+		Platform:  token.Platform,
+		Browser:   token.Browser,
+		Subnet:    subnet,
+		ClientKey: token.ClientKey,
+	}
+}
+
+// updateNetwork 将token的IP和网段更新为本次使用的
+func (token *RefreshToken) updateNetwork(fingerprint Fingerprint) {
+	if fingerprint.IP == "" {
+		return
+	}
+	token.IP = fingerprint.IP
+	token.Subnet = fingerprint.Subnet
+}
+
+// IPSubnet ip所在的网段，如 "192.168.1.0/24"，ip无效时返回空
+func IPSubnet(ip string) string {
+	parsed := net.ParseIP(strings.TrimSpace(ip))
+	if parsed == nil {
+		return ""
+	}
+	if ipv4 := parsed.To4(); ipv4 != nil {
+		network := net.IPNet{IP: ipv4.Mask(net.CIDRMask(IPV4_SUBNET_BITS, 32)), Mask: net.CIDRMask(IPV4_SUBNET_BITS, 32)}
+		return network.String()
+	}
+	network := net.IPNet{IP: parsed.Mask(net.CIDRMask(IPV6_SUBNET_BITS, 128)), Mask: net.CIDRMask(IPV6_SUBNET_BITS, 128)}
+	return network.String()
+}
+
+// ParseUserAgent 从User-Agent中解析平台和浏览器，无法识别时返回空
+func ParseUserAgent(userAgent string) (platform, browser string) {
+	return parsePlatform(userAgent), parseBrowser(userAgent)
+}
+
+// uaRule User-Agent中包含任一关键字时，识别为name。按顺序匹配，越具体的规则越靠前
+type uaRule struct {
+	name     string
+	keywords []string
+}
+
+var platformRules = []uaRule{
+	{"Windows Phone", []string{"Windows Phone"}},
+	{"Windows", []string{"Windows"}},
+	{"iOS", []string{"iPhone", "iPad", "iPod"}},
+	{"Android", []string{"Android"}},
+	{"ChromeOS", []string{"CrOS"}},
+	{"macOS", []string{"Macintosh", "Mac OS X"}},
+	{"Linux", []string{"Linux"}},
+}
+
+var browserRules = []uaRule{
+	{"WeChat", []string{"MicroMessenger"}},
+	{"Edge", []string{"Edg/", "EdgA/", "EdgiOS/", "Edge/"}},
+	{"Opera", []string{"OPR/", "Opera"}},
+	{"Samsung Internet", []string{"SamsungBrowser"}},
+	{"Firefox", []string{"Firefox/", "FxiOS/"}},
+	{"Chrome", []string{"CriOS/", "Chrome/", "Chromium/"}},
+	{"Safari", []string{"Safari/"}},
+}
+
+func parsePlatform(userAgent string) string {
+	return matchUARule(platformRules, userAgent)
+}
+
+func parseBrowser(userAgent string) string {
+	return matchUARule(browserRules, userAgent)
+}
+
+func matchUARule(rules []uaRule, userAgent string) string {
+	for _, rule := range rules {
+		for _, keyword := range rule.keywords {
+			if strings.Contains(userAgent, keyword) {
+				return rule.name
+			}
+		}
+	}
+	return ""
+}
+
+// hashClientKey 只保存客户端key的哈希
+func hashClientKey(clientKey string) string {
+	if clientKey == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(clientKey))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,153 @@
+package refresh_token
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	macChromeUA   = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36"
+	macSafariUA   = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Safari/605.1.15"
+	iPhoneUA      = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1"
+	windowsEdgeUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36 Edg/126.0.0.0"
+	androidUA     = "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Mobile Safari/537.36"
+)
+
+func TestParseUserAgent(t *testing.T) {
+	assertion := assert.New(t)
+	cases := []struct {
+		userAgent, platform, browser string
+	}{
+		{macChromeUA, "macOS", "Chrome"},
+		{macSafariUA, "macOS", "Safari"},
+		{iPhoneUA, "iOS", "Safari"},
+		{windowsEdgeUA, "Windows", "Edge"},
+		{androidUA, "Android", "Chrome"},
+		{"curl/8.0", "", ""},
+	}
+	for _, c := range cases {
+		platform, browser := ParseUserAgent(c.userAgent)
+		assertion.Equal(c.platform, platform, c.userAgent)
+		assertion.Equal(c.browser, browser, c.userAgent)
+	}
+}
+
+func TestIPSubnet(t *testing.T) {
+	assertion := assert.New(t)
+	assertion.Equal("192.168.1.0/24", IPSubnet("192.168.1.23"))
+	assertion.Equal("2001:db8:1::/48", IPSubnet("2001:db8:1:2::5"))
+	assertion.Equal("", IPSubnet("not an ip"))
+}
+
+func TestFingerprintSameDevice(t *testing.T) {
+	assertion := assert.New(t)
+	laptop := NewFingerprint(macChromeUA, "192.168.1.23", "")
+	assertion.True(laptop.SameDevice(NewFingerprint(macChromeUA, "192.168.1.99", "")))
+	assertion.False(laptop.SameDevice(NewFingerprint(macChromeUA, "10.0.0.1", "")))
+	assertion.False(laptop.SameDevice(NewFingerprint(macSafariUA, "192.168.1.23", "")))
+
+	// 有ClientKey时ip变化不影响
+	phone := NewFingerprint(iPhoneUA, "10.0.0.1", "client-key")
+	assertion.NotEqual("client-key", phone.ClientKey)
+	assertion.True(phone.SameDevice(NewFingerprint(iPhoneUA, "172.16.0.1", "client-key")))
+	assertion.False(phone.SameDevice(NewFingerprint(iPhoneUA, "10.0.0.1", "other-key")))
+}
+
+func TestGetTokenAndUpdateWithClientKey(t *testing.T) {
+	assertion := assert.New(t)
+	tokens := RefreshTokens{}
+	options := LoginOptions{IP: "10.0.0.1", UserAgent: iPhoneUA, ClientKey: "client-key"}
+	first := tokens.GetTokenAndUpdateWithOptions(options, testConfig)
+	require.Len(t, tokens.Tokens, 1)
+	assertion.Equal("iOS", tokens.Tokens[0].Platform)
+	assertion.Equal("10.0.0.0/24", tokens.Tokens[0].Subnet)
+
+	// 手机换了ip，仍是同一设备
+	options.IP = "172.16.0.1"
+	again := tokens.GetTokenAndUpdateWithOptions(options, testConfig)
+	assertion.Equal(first.RefreshToken, again.RefreshToken)
+	assertion.True(again.Updated)
+	require.Len(t, tokens.Tokens, 1)
+	assertion.Equal("172.16.0.1", tokens.Tokens[0].IP)
+}
+
+func TestDeviceRiskChecker(t *testing.T) {
+	assertion := assert.New(t)
+	token := testConfig.NewRefreshTokenWithOptions(LoginOptions{IP: "192.168.1.23", UserAgent: macChromeUA, ClientKey: "client-key"})
+
+	flagging := NewDeviceRiskChecker(RISK_FLAG)
+	risk := flagging.Check(token, NewFingerprint(macChromeUA, "192.168.1.99", "client-key"))
+	assertion.Equal(RISK_NONE, risk.Level)
+
+	risk = flagging.Check(token, NewFingerprint(macChromeUA, "10.0.0.1", "client-key"))
+	assertion.Equal(RISK_FLAG, risk.Level)
+	assertion.Equal([]string{RISK_REASON_NEW_NETWORK}, risk.Reasons)
+
+	risk = flagging.Check(token, NewFingerprint(macChromeUA, "192.168.1.23", "stolen"))
+	assertion.Equal(RISK_REJECT, risk.Level)
+	assertion.Contains(risk.Reasons, RISK_REASON_CLIENT_KEY_MISMATCH)
+
+	risk = flagging.Check(token, NewFingerprint(windowsEdgeUA, "192.168.1.23", "client-key"))
+	assertion.Equal(RISK_REJECT, risk.Level)
+	assertion.Equal([]string{RISK_REASON_PLATFORM_CHANGED, RISK_REASON_BROWSER_CHANGED}, risk.Reasons)
+
+	// 使用自定义的网络标识，如国家
+	country := map[string]string{"192.168.1.23": "CN", "192.168.9.9": "CN", "8.8.8.8": "US"}
+	rejecting := &DeviceRiskChecker{NewNetwork: RISK_REJECT, Network: func(ip string) string { return country[ip] }}
+	assertion.Equal(RISK_NONE, rejecting.Check(token, NewFingerprint(macChromeUA, "192.168.9.9", "client-key")).Level)
+	assertion.Equal(RISK_REJECT, rejecting.Check(token, NewFingerprint(macChromeUA, "8.8.8.8", "client-key")).Level)
+}
+
+func TestUseTokenWithFingerprint(t *testing.T) {
+	assertion := assert.New(t)
+	var events []RiskEvent
+	config := &RefreshTokenConfig{
+		Duration:    testConfig.Duration,
+		RiskChecker: NewDeviceRiskChecker(RISK_FLAG),
+		OnRisk:      func(event RiskEvent) { events = append(events, event) },
+	}
+	tokens := RefreshTokens{}
+	result := tokens.GetTokenAndUpdateWithOptions(LoginOptions{IP: "192.168.1.23", UserAgent: macChromeUA}, config)
+
+	_, risk, err := tokens.UseTokenWithFingerprint(result.RefreshToken, NewFingerprint(macChromeUA, "192.168.1.50", ""), config)
+	require.NoError(t, err)
+	assertion.Equal(RISK_NONE, risk.Level)
+	assertion.Empty(events)
+
+	// 新网段：允许使用并通知，之后以新网段为准
+	token, risk, err := tokens.UseTokenWithFingerprint(result.RefreshToken, NewFingerprint(macChromeUA, "10.0.0.1", ""), config)
+	require.NoError(t, err)
+	assertion.Equal(RISK_FLAG, risk.Level)
+	require.Len(t, events, 1)
+	assertion.Equal("10.0.0.1", events[0].Fingerprint.IP)
+	assertion.Equal("10.0.0.0/24", token.Subnet)
+
+	// 其他平台：拒绝
+	_, risk, err = tokens.UseTokenWithFingerprint(result.RefreshToken, NewFingerprint(androidUA, "10.0.0.1", ""), config)
+	assertion.Equal(ErrRiskRejected, err)
+	assertion.Equal(RISK_REJECT, risk.Level)
+	assertion.Len(events, 2)
+}
+
+func TestSessionManagerTouchWithFingerprint(t *testing.T) {
+	assertion := assert.New(t)
+	manager := NewSessionManager(NewMemoryStore(), 0)
+	manager.RiskChecker = NewDeviceRiskChecker(RISK_REJECT)
+	token := testConfig.NewRefreshTokenWithOptions(LoginOptions{IP: "192.168.1.23", UserAgent: macChromeUA})
+	token.UserID = "fingerprint-test"
+	_, err := manager.CreateSession(token)
+	require.NoError(t, err)
+
+	touched, _, err := manager.TouchWithFingerprint(token.Token, NewFingerprint(macChromeUA, "192.168.1.24", ""))
+	require.NoError(t, err)
+	assertion.Equal("192.168.1.24", touched.IP)
+
+	_, risk, err := manager.TouchWithFingerprint(token.Token, NewFingerprint(macChromeUA, "10.0.0.1", ""))
+	assertion.Equal(ErrRiskRejected, err)
+	assertion.Equal([]string{RISK_REASON_NEW_NETWORK}, risk.Reasons)
+	found, err := manager.Store.Lookup(token.Token)
+	require.NoError(t, err)
+	assertion.Equal("192.168.1.24", found.IP)
+}
@@ -0,0 +1,153 @@
+package refresh_token
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+)
+
+// MIN_PEPPER_LENGTH pepper的最小长度(字节)
+const MIN_PEPPER_LENGTH = 32
+
+var ErrPepperTooShort = errors.New("refresh token pepper is too short")
+
+// TokenHasher 用服务端的pepper对refresh token做HMAC-SHA256，数据库中只保存哈希，读取数据库的人无法用其中的token登录
+type TokenHasher struct {
+	pepper []byte
+}
+
+// NewTokenHasher 新建一个TokenHasher，pepper应保存在配置或密钥管理中而不是数据库里，至少32字节
+func NewTokenHasher(pepper []byte) (*TokenHasher, error) {
+	if len(pepper) < MIN_PEPPER_LENGTH {
+		return nil, ErrPepperTooShort
+	}
+	return &TokenHasher{pepper: pepper}, nil
+}
+
+// Hash 计算token字符串的HMAC-SHA256，hex编码
+func (h *TokenHasher) Hash(tokenString string) string {
+	mac := hmac.New(sha256.New, h.pepper)
+	mac.Write([]byte(tokenString))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Matches 以常量时间判断token是否对应tokenString。TokenHash为空(尚未迁移)的token比较原始字符串；h为nil时只比较原始字符串
+func (h *TokenHasher) Matches(token RefreshToken, tokenString string) bool {
+	var hash string
+	if h != nil && token.TokenHash != "" {
+		hash = h.Hash(tokenString)
+	}
+	return matchesHash(token, tokenString, hash)
+}
+
+// matchesHash 同Matches，hash为已计算好的tokenString的HMAC，为空时只比较原始字符串
+func matchesHash(token RefreshToken, tokenString, hash string) bool {
+	if token.TokenHash != "" {
+		return hash != "" && hmac.Equal([]byte(token.TokenHash), []byte(hash))
+	}
+	if token.Token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token.Token), []byte(tokenString)) == 1
+}
+
+// HashToken 将token转为哈希保存的形式：设置TokenHash并清空Token。已经哈希过的token原样返回
+func (h *TokenHasher) HashToken(token RefreshToken) RefreshToken {
+	if token.TokenHash == "" {
+		token.TokenHash = h.Hash(token.Token)
+	}
+	token.Token = ""
+	return token
+}
+
+// HashTokens 将tokens中未哈希的token全部转为哈希保存，返回转换的数量
+func (tokens *RefreshTokens) HashTokens(hasher *TokenHasher) int {
+	migrated := 0
+	for i, token := range tokens.Tokens {
+		if token.TokenHash == "" {
+			tokens.Tokens[i] = hasher.HashToken(token)
+			migrated++
+		}
+	}
+	return migrated
+}
+
+// MigrateRefreshTokens 将保存在JsonField中的RefreshTokens转为哈希保存的形式，返回新的json和转换的数量。
+// data可以是GetMarshaledTokens生成的数组，也可以是RefreshTokens对象，返回的json与输入的格式相同；可以重复执行。
+func MigrateRefreshTokens(data []byte, hasher *TokenHasher) ([]byte, int, error) {
+	var tokens RefreshTokens
+	if err := json.Unmarshal(data, &tokens.Tokens); err == nil {
+		migrated := tokens.HashTokens(hasher)
+		if migrated == 0 {
+			return data, 0, nil
+		}
+		migratedData, err := json.Marshal(tokens.Tokens)
+		return migratedData, migrated, err
+	}
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, 0, err
+	}
+	migrated := tokens.HashTokens(hasher)
+	if migrated == 0 {
+		return data, 0, nil
+	}
+	migratedData, err := json.Marshal(tokens)
+	return migratedData, migrated, err
+}
+
+// HashedStore 包装一个RefreshTokenStore，只保存token的HMAC。
+// 传入的token字符串都是客户端持有的原始token；Lookup、ListByUser返回的token中Token为空，只有TokenHash。
+type HashedStore struct {
+	Store  RefreshTokenStore
+	Hasher *TokenHasher
+}
+
+// NewHashedStore 新建一个HashedStore
+func NewHashedStore(store RefreshTokenStore, hasher *TokenHasher) *HashedStore {
+	return &HashedStore{
+		Store:  store,
+		Hasher: hasher,
+	}
+}
+
+func (s *HashedStore) Create(token RefreshToken) error {
+	return s.Store.Create(s.Hasher.HashToken(token))
+}
+
+func (s *HashedStore) Lookup(tokenString string) (*RefreshToken, error) {
+	return s.Store.Lookup(s.Hasher.Hash(tokenString))
+}
+
+func (s *HashedStore) Rotate(oldTokenString string, newToken RefreshToken) error {
+	return s.Store.Rotate(s.Hasher.Hash(oldTokenString), s.Hasher.HashToken(newToken))
+}
+
+// Update token可以是Lookup、ListByUser返回的(已有TokenHash)，也可以带有原始Token
+func (s *HashedStore) Update(token RefreshToken) error {
+	return s.Store.Update(s.Hasher.HashToken(token))
+}
+
+func (s *HashedStore) Revoke(tokenString string) error {
+	return s.Store.Revoke(s.Hasher.Hash(tokenString))
+}
+
+func (s *HashedStore) ListByUser(userID string) ([]RefreshToken, error) {
+	return s.Store.ListByUser(userID)
+}
+
+func (s *HashedStore) PurgeExpired(userID string) (int, error) {
+	return s.Store.PurgeExpired(userID)
+}
+
+// revokeStored 吊销ListByUser返回的token
+func (s *HashedStore) revokeStored(token RefreshToken) error {
+	return s.Store.Revoke(s.Hasher.HashToken(token).TokenHash)
+}
+
+// matches 判断ListByUser返回的token是否对应原始的tokenString
+func (s *HashedStore) matches(token RefreshToken, tokenString string) bool {
+	return s.Hasher.Matches(token, tokenString)
+}
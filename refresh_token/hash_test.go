@@ -0,0 +1,145 @@
+package refresh_token
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/adamesong/go-util/random"
+	"github.com/adamesong/go-util/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestHasher(t *testing.T) *TokenHasher {
+	hasher, err := NewTokenHasher([]byte(random.RandomString(MIN_PEPPER_LENGTH)))
+	require.NoError(t, err)
+	return hasher
+}
+
+func TestTokenHasher(t *testing.T) {
+	assertion := assert.New(t)
+	_, err := NewTokenHasher([]byte("short"))
+	assertion.Equal(ErrPepperTooShort, err)
+
+	hasher := newTestHasher(t)
+	token := testConfig.NewRefreshToken("127.0.0.1", "macOS", "Chrome")
+	raw := token.Token
+	hashed := hasher.HashToken(token)
+	assertion.Empty(hashed.Token)
+	assertion.Equal(hasher.Hash(raw), hashed.TokenHash)
+	assertion.Equal(hashed, hasher.HashToken(hashed))
+
+	assertion.True(hasher.Matches(hashed, raw))
+	assertion.False(hasher.Matches(hashed, hashed.TokenHash))
+	assertion.True(hasher.Matches(token, raw))
+	assertion.False((*TokenHasher)(nil).Matches(hashed, raw))
+
+	// pepper不同，哈希不同
+	assertion.False(newTestHasher(t).Matches(hashed, raw))
+}
+
+func TestHashedRefreshTokens(t *testing.T) {
+	assertion := assert.New(t)
+	config := &RefreshTokenConfig{Duration: testConfig.Duration, Hasher: newTestHasher(t)}
+	tokens := RefreshTokens{}
+
+	first, updated := tokens.GetTokenAndUpdate("127.0.0.1", "macOS", "Chrome", config)
+	assertion.True(updated)
+	require.Len(t, tokens.Tokens, 1)
+	assertion.Empty(tokens.Tokens[0].Token)
+	assertion.NotContains(string(tokens.GetMarshaledTokens()), first)
+	assertion.NotNil(tokens.Find(first, config.Hasher))
+	assertion.False(tokens.ContainsTokenString(first))
+
+	// 同一设备再次登录时换发新token，旧token失效
+	second, updated := tokens.GetTokenAndUpdate("127.0.0.1", "macOS", "Chrome", config)
+	assertion.True(updated)
+	assertion.NotEqual(first, second)
+	require.Len(t, tokens.Tokens, 1)
+	assertion.Nil(tokens.Find(first, config.Hasher))
+	assertion.NotNil(tokens.Find(second, config.Hasher))
+}
+
+func TestMigrateRefreshTokens(t *testing.T) {
+	assertion := assert.New(t)
+	hasher := newTestHasher(t)
+	legacy := RefreshTokens{}
+	first, _ := legacy.GetTokenAndUpdate("127.0.0.1", "macOS", "Chrome", testConfig)
+	second, _ := legacy.GetTokenAndUpdate("10.0.0.1", "iOS", "Safari", testConfig)
+	assertion.True(legacy.ContainsValidTokenString(first))
+
+	// GetMarshaledTokens生成的数组
+	data, migrated, err := MigrateRefreshTokens(legacy.GetMarshaledTokens(), hasher)
+	require.NoError(t, err)
+	assertion.Equal(2, migrated)
+	assertion.NotContains(string(data), first)
+	var tokens RefreshTokens
+	require.NoError(t, json.Unmarshal(data, &tokens.Tokens))
+	assertion.NotNil(tokens.Find(first, hasher))
+	assertion.NotNil(tokens.Find(second, hasher))
+
+	// 重复执行不改变数据
+	again, migrated, err := MigrateRefreshTokens(data, hasher)
+	require.NoError(t, err)
+	assertion.Equal(0, migrated)
+	assertion.Equal(data, again)
+
+	// RefreshTokens对象
+	object, err := json.Marshal(legacy)
+	require.NoError(t, err)
+	data, migrated, err = MigrateRefreshTokens(object, hasher)
+	require.NoError(t, err)
+	assertion.Equal(2, migrated)
+	tokens = RefreshTokens{}
+	require.NoError(t, json.Unmarshal(data, &tokens))
+	assertion.NotNil(tokens.Find(second, hasher))
+
+	_, _, err = MigrateRefreshTokens([]byte("not json"), hasher)
+	assertion.Error(err)
+}
+
+func TestHashedStore(t *testing.T) {
+	hasher := newTestHasher(t)
+	t.Run("memory", func(t *testing.T) {
+		testHashedStore(t, NewHashedStore(NewMemoryStore(), hasher))
+	})
+	t.Run("redis", func(t *testing.T) {
+		redisClient, err := redis.NewRedisClient("localhost:6379", "", 0)
+		require.NoError(t, err, "Failed to initialize redis client for test")
+		defer redisClient.Close()
+		testHashedStore(t, NewHashedStore(NewRedisStore(redisClient), hasher))
+	})
+}
+
+func testHashedStore(t *testing.T, store *HashedStore) {
+	testStore(t, store)
+
+	// 底层store中只有哈希
+	assertion := assert.New(t)
+	userID := "hashed-test-" + random.RandomString(8)
+	token := newUserToken(userID)
+	require.NoError(t, store.Create(token))
+	_, err := store.Store.Lookup(token.Token)
+	assertion.Equal(ErrTokenNotFound, err)
+	found, err := store.Lookup(token.Token)
+	require.NoError(t, err)
+	assertion.Empty(found.Token)
+	_, err = store.Lookup(found.TokenHash)
+	assertion.Equal(ErrTokenNotFound, err)
+
+	// SessionManager使用原始token标记当前设备和吊销其他设备
+	manager := NewSessionManager(store, 0)
+	other := newUserToken(userID)
+	_, err = manager.CreateSession(other)
+	require.NoError(t, err)
+	sessions, err := manager.ListSessions(userID, token.Token)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	revoked, err := manager.RevokeOtherSessions(userID, token.Token)
+	require.NoError(t, err)
+	assertion.Equal(1, revoked)
+	require.NoError(t, manager.RevokeSession(userID, token.ID))
+	sessions, err = manager.ListSessions(userID, "")
+	require.NoError(t, err)
+	assertion.Empty(sessions)
+}
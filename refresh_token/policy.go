@@ -0,0 +1,133 @@
+package refresh_token
+
+import (
+	"errors"
+	"time"
+)
+
+// ExpiryReason refresh token过期的原因，用于提示用户重新登录
+type ExpiryReason string
+
+const (
+	EXPIRY_REASON_NONE     ExpiryReason = ""         // 未过期
+	EXPIRY_REASON_IDLE     ExpiryReason = "idle"     // 超过IdleTimeout未使用
+	EXPIRY_REASON_LIFETIME ExpiryReason = "lifetime" // 达到最长有效期
+)
+
+var (
+	ErrTokenIdleExpired     = errors.New("refresh token expired due to inactivity")
+	ErrTokenLifetimeExpired = errors.New("refresh token reached its maximum lifetime")
+)
+
+// ExpiryPolicy refresh token的过期策略，两者都设置时，先到者为准
+type ExpiryPolicy struct {
+	IdleTimeout time.Duration // 多久未使用后过期，每次使用时顺延。0为不限制
+	MaxLifetime time.Duration // 从登录起的最长有效期，不随使用顺延。0为不限制
+}
+
+// LoginOptions 登录时的设备信息和选项
+type LoginOptions struct {
+	IP         string
+	Platform   string // 为空时从UserAgent解析
+	Browser    string // 为空时从UserAgent解析
+	UserAgent  string
+	ClientKey  string // 客户端生成并保存的随机key，可为空，见Fingerprint
+	RememberMe bool   // 是否勾选了"记住我"，使用RefreshTokenConfig.RememberMe的过期策略
+}
+
+// LoginResult GetTokenAndUpdateWithOptions的结果
+type LoginResult struct {
+	RefreshToken string       // 返回给客户端的refresh token
+	Updated      bool         // tokens是否被改变，需要保存
+	ExpiryReason ExpiryReason // 该设备(见Fingerprint.SameDevice)原来的token已过期时，过期的原因
+}
+
+// fingerprint 登录设备的指纹
+func (options LoginOptions) fingerprint() Fingerprint {
+	fingerprint := NewFingerprint(options.UserAgent, options.IP, options.ClientKey)
+	if options.Platform != "" {
+		fingerprint.Platform = options.Platform
+	}
+	if options.Browser != "" {
+		fingerprint.Browser = options.Browser
+	}
+	return fingerprint
+}
+
+// Err 过期原因对应的错误，未过期时返回nil
+func (reason ExpiryReason) Err() error {
+	switch reason {
+	case EXPIRY_REASON_IDLE:
+		return ErrTokenIdleExpired
+	case EXPIRY_REASON_LIFETIME:
+		return ErrTokenLifetimeExpired
+	default:
+		return nil
+	}
+}
+
+// ExpiryReason token过期的原因，未过期时返回EXPIRY_REASON_NONE。没有设置IdleTimeout的token只会因有效期到达而过期
+func (token *RefreshToken) ExpiryReason() ExpiryReason {
+	if !token.Expired() {
+		return EXPIRY_REASON_NONE
+	}
+	if token.IdleTimeout > 0 && (token.MaxExpiresAt.IsZero() || time.Now().Before(token.MaxExpiresAt)) {
+		return EXPIRY_REASON_IDLE
+	}
+	return EXPIRY_REASON_LIFETIME
+}
+
+// touch 记录使用时间，并按IdleTimeout顺延有效期
+func (token *RefreshToken) touch(now time.Time) {
+	token.LastUsedAt = now
+	if token.IdleTimeout > 0 {
+		token.ExpiresAt = token.slidingExpiry(now)
+	}
+}
+
+// slidingExpiry 在now使用后的过期时间
+func (token *RefreshToken) slidingExpiry(now time.Time) time.Time {
+	if token.IdleTimeout <= 0 {
+		return token.MaxExpiresAt
+	}
+	expiresAt := now.Add(token.IdleTimeout)
+	if !token.MaxExpiresAt.IsZero() && token.MaxExpiresAt.Before(expiresAt) {
+		return token.MaxExpiresAt
+	}
+	return expiresAt
+}
+
+// rotatedFrom 轮换token时，新token沿用old的会话ID、创建时间和过期策略，有效期不超过old的绝对过期时间。
+// 否则每次轮换都会重新计算MaxLifetime，并在设备列表中显示为新的设备
+func (token RefreshToken) rotatedFrom(old RefreshToken, now time.Time) RefreshToken {
+	if old.ID != "" {
+		token.ID = old.ID
+	}
+	if !old.CreatedAt.IsZero() {
+		token.CreatedAt = old.CreatedAt
+	}
+	if token.Name == "" {
+		token.Name = old.Name
+	}
+	token.RememberMe = old.RememberMe
+	token.IdleTimeout = old.IdleTimeout
+	token.MaxExpiresAt = old.MaxExpiresAt
+	if token.IdleTimeout > 0 {
+		token.ExpiresAt = token.slidingExpiry(now)
+	} else if !token.MaxExpiresAt.IsZero() && token.MaxExpiresAt.Before(token.ExpiresAt) {
+		token.ExpiresAt = token.MaxExpiresAt
+	}
+	return token
+}
+
+// policy 登录使用的过期策略。没有设置Policy，或Policy两项都为0时，以Duration为最长有效期
+func (cfg *RefreshTokenConfig) policy(rememberMe bool) ExpiryPolicy {
+	policy := cfg.Policy
+	if rememberMe && cfg.RememberMe != nil {
+		policy = cfg.RememberMe
+	}
+	if policy == nil || (policy.IdleTimeout <= 0 && policy.MaxLifetime <= 0) {
+		return ExpiryPolicy{MaxLifetime: cfg.Duration}
+	}
+	return *policy
+}
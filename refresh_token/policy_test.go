@@ -0,0 +1,107 @@
+package refresh_token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpiryPolicy(t *testing.T) {
+	assertion := assert.New(t)
+	config := &RefreshTokenConfig{
+		Duration:   time.Hour,
+		Policy:     &ExpiryPolicy{IdleTimeout: time.Hour, MaxLifetime: 24 * time.Hour},
+		RememberMe: &ExpiryPolicy{IdleTimeout: 30 * 24 * time.Hour, MaxLifetime: 90 * 24 * time.Hour},
+	}
+
+	token := config.NewRefreshToken("127.0.0.1", "macOS", "Chrome")
+	assertion.WithinDuration(time.Now().Add(time.Hour), token.ExpiresAt, time.Second)
+	assertion.WithinDuration(time.Now().Add(24*time.Hour), token.MaxExpiresAt, time.Second)
+	remembered := config.NewRefreshTokenWithOptions(LoginOptions{IP: "127.0.0.1", RememberMe: true})
+	assertion.WithinDuration(time.Now().Add(30*24*time.Hour), remembered.ExpiresAt, time.Second)
+
+	// 使用后顺延，但不超过最长有效期
+	token.touch(time.Now().Add(30 * time.Minute))
+	assertion.WithinDuration(time.Now().Add(90*time.Minute), token.ExpiresAt, time.Second)
+	token.touch(token.MaxExpiresAt.Add(-time.Minute))
+	assertion.Equal(token.MaxExpiresAt, token.ExpiresAt)
+
+	// 过期原因
+	assertion.Equal(EXPIRY_REASON_NONE, token.ExpiryReason())
+	idle := config.NewRefreshToken("127.0.0.1", "macOS", "Chrome")
+	idle.ExpiresAt = time.Now().Add(-time.Minute)
+	assertion.Equal(EXPIRY_REASON_IDLE, idle.ExpiryReason())
+	assertion.Equal(ErrTokenIdleExpired, idle.ExpiryReason().Err())
+	idle.MaxExpiresAt = idle.ExpiresAt
+	assertion.Equal(EXPIRY_REASON_LIFETIME, idle.ExpiryReason())
+
+	// 只有Duration时与原来一样，不顺延
+	fixed := testConfig.NewRefreshToken("127.0.0.1", "macOS", "Chrome")
+	expiresAt := fixed.ExpiresAt
+	fixed.touch(time.Now().Add(time.Minute))
+	assertion.Equal(expiresAt, fixed.ExpiresAt)
+	fixed.ExpiresAt = time.Now().Add(-time.Minute)
+	assertion.Equal(EXPIRY_REASON_LIFETIME, fixed.ExpiryReason())
+}
+
+func TestGetTokenAndUpdateWithOptions(t *testing.T) {
+	assertion := assert.New(t)
+	config := &RefreshTokenConfig{
+		Policy:     &ExpiryPolicy{IdleTimeout: time.Hour, MaxLifetime: 24 * time.Hour},
+		RememberMe: &ExpiryPolicy{MaxLifetime: 30 * 24 * time.Hour},
+	}
+	options := LoginOptions{IP: "127.0.0.1", Platform: "macOS", Browser: "Chrome"}
+	tokens := RefreshTokens{}
+
+	first := tokens.GetTokenAndUpdateWithOptions(options, config)
+	assertion.True(first.Updated)
+	assertion.Equal(EXPIRY_REASON_NONE, first.ExpiryReason)
+
+	// 同一设备再次登录，顺延原token
+	tokens.Tokens[0].ExpiresAt = time.Now().Add(time.Minute)
+	again := tokens.GetTokenAndUpdateWithOptions(options, config)
+	assertion.Equal(first.RefreshToken, again.RefreshToken)
+	assertion.True(again.Updated)
+	assertion.WithinDuration(time.Now().Add(time.Hour), tokens.Tokens[0].ExpiresAt, time.Second)
+
+	// 原token因闲置过期，返回过期原因
+	tokens.Tokens[0].ExpiresAt = time.Now().Add(-time.Minute)
+	relogin := tokens.GetTokenAndUpdateWithOptions(options, config)
+	assertion.NotEqual(first.RefreshToken, relogin.RefreshToken)
+	assertion.Equal(EXPIRY_REASON_IDLE, relogin.ExpiryReason)
+	require.Len(t, tokens.Tokens, 1)
+
+	// 勾选"记住我"后换发新token
+	options.RememberMe = true
+	remembered := tokens.GetTokenAndUpdateWithOptions(options, config)
+	assertion.NotEqual(relogin.RefreshToken, remembered.RefreshToken)
+	require.Len(t, tokens.Tokens, 1)
+	assertion.True(tokens.Tokens[0].RememberMe)
+	assertion.Zero(tokens.Tokens[0].IdleTimeout)
+}
+
+func TestUseToken(t *testing.T) {
+	assertion := assert.New(t)
+	config := &RefreshTokenConfig{Policy: &ExpiryPolicy{IdleTimeout: time.Hour, MaxLifetime: 24 * time.Hour}}
+	tokens := RefreshTokens{}
+	tokenString, _ := tokens.GetTokenAndUpdate("127.0.0.1", "macOS", "Chrome", config)
+
+	tokens.Tokens[0].ExpiresAt = time.Now().Add(time.Minute)
+	token, err := tokens.UseToken(tokenString, config)
+	require.NoError(t, err)
+	assertion.WithinDuration(time.Now().Add(time.Hour), token.ExpiresAt, time.Second)
+	assertion.WithinDuration(time.Now().Add(time.Hour), tokens.Tokens[0].ExpiresAt, time.Second)
+
+	tokens.Tokens[0].ExpiresAt = time.Now().Add(-time.Minute)
+	_, err = tokens.UseToken(tokenString, config)
+	assertion.Equal(ErrTokenIdleExpired, err)
+
+	tokens.Tokens[0].MaxExpiresAt = tokens.Tokens[0].ExpiresAt
+	_, err = tokens.UseToken(tokenString, config)
+	assertion.Equal(ErrTokenLifetimeExpired, err)
+
+	_, err = tokens.UseToken("unknown", config)
+	assertion.Equal(ErrTokenNotFound, err)
+}
@@ -0,0 +1,231 @@
+package refresh_token
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/adamesong/go-util/redis"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const (
+	REFRESH_TOKEN_KEY_PREFIX  = "refresh_token:"      // key为token字符串(哈希保存时为TokenHash)，value为RefreshToken的json
+	REFRESH_TOKEN_USER_PREFIX = "refresh_token_user:" // 每个用户的token索引，sorted set，member为token字符串，score为过期时间
+)
+
+// rotateScript 删除旧token并保存新token，同时更新用户索引。旧token已被删除时返回0，并发轮换同一个token时只有一个请求能成功。
+// KEYS为旧token、新token和用户索引，ARGV为新token的json、有效期(毫秒)、旧token的member、新token的score和member
+var rotateScript = goredis.NewScript(`
+if redis.call("DEL", KEYS[1]) == 0 then
+	return 0
+end
+redis.call("SET", KEYS[2], ARGV[1], "PX", ARGV[2])
+redis.call("ZREM", KEYS[3], ARGV[3])
+redis.call("ZADD", KEYS[3], ARGV[4], ARGV[5])
+if redis.call("PTTL", KEYS[3]) < tonumber(ARGV[2]) then
+	redis.call("PEXPIRE", KEYS[3], ARGV[2])
+end
+return 1
+`)
+
+// RedisStore 基于redis的RefreshTokenStore，token到期后由redis自动删除，用户索引中的过期记录由PurgeExpired清理
+type RedisStore struct {
+	Redis *redis.RedisClient
+}
+
+// NewRedisStore 新建一个RedisStore
+func NewRedisStore(client *redis.RedisClient) *RedisStore {
+	return &RedisStore{Redis: client}
+}
+
+func (s *RedisStore) Create(token RefreshToken) error {
+	if token.UserID == "" {
+		return ErrNoUserID
+	}
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	ttl := ttlOf(token)
+	if err := s.Redis.Set(REFRESH_TOKEN_KEY_PREFIX+token.key(), data, ttl); err != nil {
+		return err
+	}
+	return s.index(token, ttl)
+}
+
+func (s *RedisStore) Lookup(tokenString string) (*RefreshToken, error) {
+	token, err := s.get(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if token.Expired() {
+		return nil, ErrTokenNotFound
+	}
+	return token, nil
+}
+
+func (s *RedisStore) Rotate(oldTokenString string, newToken RefreshToken) error {
+	if newToken.UserID == "" {
+		return ErrNoUserID
+	}
+	oldToken, err := s.Lookup(oldTokenString)
+	if err != nil {
+		return err
+	}
+	newToken = newToken.rotatedFrom(*oldToken, time.Now())
+	if _, ok := s.Redis.Client.(*goredis.ClusterClient); ok {
+		// cluster中新旧token可能不在同一个slot，无法在一个脚本中完成；以删除成功作为判断，并发轮换时只有一个请求能成功
+		if err := s.remove(oldToken); err != nil {
+			return err
+		}
+		return s.Create(newToken)
+	}
+	data, err := json.Marshal(newToken)
+	if err != nil {
+		return err
+	}
+	ttl := ttlOf(newToken)
+	keys := []string{REFRESH_TOKEN_KEY_PREFIX + oldToken.key(), REFRESH_TOKEN_KEY_PREFIX + newToken.key(), REFRESH_TOKEN_USER_PREFIX + newToken.UserID}
+	rotated, err := rotateScript.Run(context.Background(), s.Redis.Client, keys,
+		data, ttl.Milliseconds(), oldToken.key(), newToken.ExpiresAt.Unix(), newToken.key()).Int()
+	if err != nil {
+		return err
+	}
+	if rotated == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+func (s *RedisStore) Update(token RefreshToken) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	ttl := ttlOf(token)
+	// SET XX只覆盖已存在的key，不会让同时被Revoke的token重新出现
+	ok, err := s.Redis.SetXX(REFRESH_TOKEN_KEY_PREFIX+token.key(), data, ttl)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrTokenNotFound
+	}
+	return s.index(token, ttl)
+}
+
+func (s *RedisStore) Revoke(tokenString string) error {
+	token, err := s.get(tokenString)
+	if err != nil {
+		return err
+	}
+	return s.remove(token)
+}
+
+func (s *RedisStore) ListByUser(userID string) ([]RefreshToken, error) {
+	tokens := make([]RefreshToken, 0)
+	members, err := s.Redis.ZRangeByScore(REFRESH_TOKEN_USER_PREFIX+userID, "("+strconv.FormatInt(time.Now().Unix(), 10), "+inf")
+	if err != nil || len(members) == 0 {
+		return tokens, err
+	}
+	keys := make([]string, len(members))
+	for i, member := range members {
+		keys[i] = REFRESH_TOKEN_KEY_PREFIX + member
+	}
+	values, err := s.Redis.MGet(keys...)
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var token RefreshToken
+		if err := json.Unmarshal([]byte(data), &token); err != nil {
+			return nil, err
+		}
+		if !token.Expired() {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens, nil
+}
+
+func (s *RedisStore) PurgeExpired(userID string) (int, error) {
+	userKey := REFRESH_TOKEN_USER_PREFIX + userID
+	members, err := s.Redis.ZRangeByScore(userKey, "-inf", strconv.FormatInt(time.Now().Unix(), 10))
+	if err != nil || len(members) == 0 {
+		return 0, err
+	}
+	keys := make([]string, len(members))
+	removed := make([]interface{}, len(members))
+	for i, member := range members {
+		keys[i] = REFRESH_TOKEN_KEY_PREFIX + member
+		removed[i] = member
+	}
+	if _, err := s.Redis.Delete(keys...); err != nil {
+		return 0, err
+	}
+	if _, err := s.Redis.ZRem(userKey, removed...); err != nil {
+		return 0, err
+	}
+	return len(members), nil
+}
+
+// get 读取token，不判断是否过期
+func (s *RedisStore) get(tokenString string) (*RefreshToken, error) {
+	data, err := s.Redis.Get(REFRESH_TOKEN_KEY_PREFIX + tokenString)
+	if err != nil {
+		if errors.Is(err, goredis.Nil) {
+			return nil, ErrTokenNotFound
+		}
+		return nil, err
+	}
+	var token RefreshToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// remove 删除token及其在用户索引中的记录，token已被删除时返回ErrTokenNotFound
+func (s *RedisStore) remove(token *RefreshToken) error {
+	n, err := s.Redis.Delete(REFRESH_TOKEN_KEY_PREFIX + token.key())
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrTokenNotFound
+	}
+	_, err = s.Redis.ZRem(REFRESH_TOKEN_USER_PREFIX+token.UserID, token.key())
+	return err
+}
+
+// index 在用户索引中记录token，索引的有效期不短于其中最晚过期的token
+func (s *RedisStore) index(token RefreshToken, ttl time.Duration) error {
+	userKey := REFRESH_TOKEN_USER_PREFIX + token.UserID
+	if _, err := s.Redis.ZAdd(userKey, redis.Z{Score: float64(token.ExpiresAt.Unix()), Member: token.key()}); err != nil {
+		return err
+	}
+	indexTTL, err := s.Redis.TTL(userKey)
+	if err != nil {
+		return err
+	}
+	if indexTTL < ttl {
+		_, err = s.Redis.Expire(userKey, ttl)
+	}
+	return err
+}
+
+// ttlOf token在redis中的有效期，至少1秒
+func ttlOf(token RefreshToken) time.Duration {
+	ttl := time.Until(token.ExpiresAt)
+	if ttl < time.Second {
+		return time.Second
+	}
+	return ttl
+}
@@ -1,6 +1,7 @@
 /*
 If refresh_token is saved in postgresql's JsonField, use this package.
 But saving refresh_token in a separate table is better.
+To move refresh tokens out of the user row, use a RefreshTokenStore (RedisStore or MemoryStore).
 */
 package refresh_token
 
@@ -14,16 +15,36 @@ import (
 )
 
 type RefreshTokenConfig struct {
-	Duration time.Duration // ie: time.Hour * 720
+	Duration   time.Duration // ie: time.Hour * 720，未设置Policy时的有效期，使用时不顺延
+	Hasher     *TokenHasher  // 设置后RefreshTokens中只保存token的哈希，见GetTokenAndUpdate
+	Policy     *ExpiryPolicy // 普通登录的过期策略，为nil时使用Duration
+	RememberMe *ExpiryPolicy // 勾选"记住我"登录时的过期策略，为nil时与普通登录相同
+
+	RiskChecker RiskChecker     // UseTokenWithFingerprint时检查设备是否可疑，为nil时不检查
+	OnRisk      func(RiskEvent) // 检查到风险时调用，如通知用户，可为nil
 }
 
 // ['refresh_token', '过期的datetime', 'ip', '设备信息', 'Browser信息']
 type RefreshToken struct {
-	Token     string    `json:"refresh_token"`
-	ExpiresAt time.Time `json:"expires_at"`
-	IP        string    `json:"ip"`
-	Platform  string    `json:"platform"`
-	Browser   string    `json:"browser"`
+	Token      string    `json:"refresh_token"`
+	TokenHash  string    `json:"token_hash,omitempty"` // 哈希保存时为Token的HMAC，此时Token为空
+	ID         string    `json:"id,omitempty"`         // 会话ID，轮换token时保持不变，用于在设备列表中识别和吊销会话
+	UserID     string    `json:"user_id,omitempty"`    // 保存在RefreshTokenStore中时使用
+	ExpiresAt  time.Time `json:"expires_at"`
+	IP         string    `json:"ip"`
+	Platform   string    `json:"platform"`
+	Browser    string    `json:"browser"`
+	Subnet     string    `json:"subnet,omitempty"`     // IP所在网段，见IPSubnet
+	ClientKey  string    `json:"client_key,omitempty"` // 客户端key的sha256，见Fingerprint
+	Name       string    `json:"name,omitempty"`       // 用户给设备起的名字
+	CreatedAt  time.Time `json:"created_at,omitzero"`
+	LastUsedAt time.Time `json:"last_used_at,omitzero"`
+	// 过期策略，见ExpiryPolicy
+	RememberMe   bool          `json:"remember_me,omitempty"`
+	IdleTimeout  time.Duration `json:"idle_timeout,omitempty"`  // 大于0时，每次使用后有效期顺延至LastUsedAt+IdleTimeout
+	MaxExpiresAt time.Time     `json:"max_expires_at,omitzero"` // 绝对过期时间，顺延不会超过这个时间
+	// 调用方保存的附加数据，如换发access token时使用的claims
+	Data json.RawMessage `json:"data,omitempty"`
 }
 
 // NotExpired 一个refresh_token是否过了有效期
@@ -32,54 +53,146 @@ func (token *RefreshToken) Expired() bool {
 	return time.Now().After(token.ExpiresAt)
 }
 
+// key 保存时使用的key，哈希保存时为TokenHash
+func (token *RefreshToken) key() string {
+	if token.TokenHash != "" {
+		return token.TokenHash
+	}
+	return token.Token
+}
+
 // 一组refresh token的结构体，用于存在用户privateInfo上
 type RefreshTokens struct {
 	Tokens []RefreshToken `json:"refresh_tokens"`
 }
 
 func (cfg *RefreshTokenConfig) NewRefreshToken(ip, platform, browser string) RefreshToken {
+	return cfg.NewRefreshTokenWithOptions(LoginOptions{IP: ip, Platform: platform, Browser: browser})
+}
 
+// NewRefreshTokenWithOptions 按登录选项(是否"记住我")对应的过期策略生成refresh token
+func (cfg *RefreshTokenConfig) NewRefreshTokenWithOptions(options LoginOptions) RefreshToken {
+	now := time.Now()
+	policy := cfg.policy(options.RememberMe)
+	fingerprint := options.fingerprint()
 	refreshToken := RefreshToken{
-		Token:     uuid.NewString(),
-		ExpiresAt: time.Now().Add(cfg.Duration),
-		IP:        ip,
-		Platform:  platform,
-		Browser:   browser,
+		Token:       uuid.NewString(),
+		ID:          uuid.NewString(),
+		IP:          fingerprint.IP,
+		Platform:    fingerprint.Platform,
+		Browser:     fingerprint.Browser,
+		Subnet:      fingerprint.Subnet,
+		ClientKey:   fingerprint.ClientKey,
+		CreatedAt:   now,
+		LastUsedAt:  now,
+		RememberMe:  options.RememberMe,
+		IdleTimeout: policy.IdleTimeout,
+	}
+	if policy.MaxLifetime > 0 {
+		refreshToken.MaxExpiresAt = now.Add(policy.MaxLifetime)
 	}
+	refreshToken.ExpiresAt = refreshToken.slidingExpiry(now)
 	return refreshToken
 }
 
 // GetTokenAndUpdate
-// 提供用户登录的ip、平台、浏览器(不做判断)，获得refresh_token(string)，并根据ip、平台是否相同，来更新有效的refresh_token list
+// 提供用户登录的ip、平台、浏览器，获得refresh_token(string)，并根据是否是同一设备(见Fingerprint.SameDevice)，来更新有效的refresh_token list
 // 此过程中，会删除过期的refresh_token。
 // 如果删除了过期的refresh_token，或者生成了新的refresh_token，则updated返回true(老数据被改变)，否则false。
+// config.Hasher不为空时，list中只保存哈希，无法取回原来的token，所以ip和平台一致时也会为该设备换发新的token，
+// 同时把尚未哈希的老数据转为哈希保存。
 func (tokens *RefreshTokens) GetTokenAndUpdate(ip, platform, browser string, config *RefreshTokenConfig) ( //newRefreshTokens RefreshTokens,
 	refreshToken string, updated bool) {
-	updated = false
+	result := tokens.GetTokenAndUpdateWithOptions(LoginOptions{IP: ip, Platform: platform, Browser: browser}, config)
+	return result.RefreshToken, result.Updated
+}
+
+// GetTokenAndUpdateWithOptions 同GetTokenAndUpdate，另外支持User-Agent、客户端key和"记住我"，并返回该设备原来的token因何过期。
+// 该设备已有未过期的token时，按IdleTimeout顺延其有效期；"记住我"的选择与原token不同时，换发新的token。
+func (tokens *RefreshTokens) GetTokenAndUpdateWithOptions(options LoginOptions, config *RefreshTokenConfig) (result LoginResult) {
+	now := time.Now()
+	fingerprint := options.fingerprint()
 	newTokens := make([]RefreshToken, 0) // 用于存更新后的数据
 	for _, token := range tokens.Tokens {
-		if !token.Expired() { // 如果该refresh_token没有过期
-			if token.IP == ip && token.Platform == platform { // 如果ip和平台都一致，则返回这个refresh_token
-				refreshToken = token.Token
-				newTokens = append(newTokens, token)
-			} else { // if token.IP != ip || token.Platform != platform  // 如果不一致，则继续存在list中
-				newTokens = append(newTokens, token)
+		sameDevice := token.Fingerprint().SameDevice(fingerprint)
+		if token.Expired() { // 如果tokens里的某条token过期了，删除过期的token，即不append到新数据里
+			if sameDevice {
+				result.ExpiryReason = token.ExpiryReason()
 			}
-		} else { // 如果tokens里的某条token过期了，删除过期的token，即不append到新数据里
-			updated = true
+			result.Updated = true
+			continue
 		}
+		if config.Hasher != nil && token.TokenHash == "" { // 老数据转为哈希保存
+			token = config.Hasher.HashToken(token)
+			result.Updated = true
+		}
+		if !sameDevice || result.RefreshToken != "" { // 如果不是同一设备，则继续存在list中
+			newTokens = append(newTokens, token)
+			continue
+		}
+		if token.RememberMe != options.RememberMe { // 过期策略不同，删除后重新生成
+			result.Updated = true
+			continue
+		}
+		// 如果是同一设备，则返回这个refresh_token
+		if token.IP != fingerprint.IP { // 同一设备换了ip(如使用ClientKey的手机)
+			token.updateNetwork(fingerprint)
+			result.Updated = true
+		}
+		if config.Hasher != nil {
+			result.RefreshToken = uuid.NewString()
+			token.TokenHash = config.Hasher.Hash(result.RefreshToken)
+			token.touch(now)
+			result.Updated = true
+		} else {
+			result.RefreshToken = token.Token
+			if token.IdleTimeout > 0 {
+				token.touch(now)
+				result.Updated = true
+			}
+		}
+		newTokens = append(newTokens, token)
 	}
-	if refreshToken == "" { // 如果循环完了array没有找到符合条件的老token，则生成一个新token，并append到list中
-		newToken := config.NewRefreshToken(ip, platform, browser)
+	if result.RefreshToken == "" { // 如果循环完了array没有找到符合条件的老token，则生成一个新token，并append到list中
+		newToken := config.NewRefreshTokenWithOptions(options)
+		result.RefreshToken = newToken.Token
+		if config.Hasher != nil {
+			newToken = config.Hasher.HashToken(newToken)
+		}
 		newTokens = append(newTokens, newToken)
-		refreshToken = newToken.Token
-		updated = true
+		result.Updated = true
 	}
 
 	tokens.Tokens = newTokens
 	return
 }
 
+// UseToken 客户端用refresh token换取access token时调用：检查token是否有效，并按IdleTimeout顺延有效期。
+// 返回的token指向tokens中的元素，调用后需保存tokens。过期时返回ErrTokenIdleExpired或ErrTokenLifetimeExpired，不存在时返回ErrTokenNotFound
+func (tokens *RefreshTokens) UseToken(tokenString string, config *RefreshTokenConfig) (*RefreshToken, error) {
+	token, _, err := tokens.UseTokenWithFingerprint(tokenString, Fingerprint{}, config)
+	return token, err
+}
+
+// UseTokenWithFingerprint 同UseToken，另外用config.RiskChecker检查本次使用的设备，RISK_REJECT时返回ErrRiskRejected。
+// 检查通过时将token的IP和网段更新为本次使用的
+func (tokens *RefreshTokens) UseTokenWithFingerprint(tokenString string, fingerprint Fingerprint, config *RefreshTokenConfig) (*RefreshToken, Risk, error) {
+	token := tokens.Find(tokenString, config.Hasher)
+	if token == nil {
+		return nil, Risk{}, ErrTokenNotFound
+	}
+	if token.Expired() {
+		return nil, Risk{}, token.ExpiryReason().Err()
+	}
+	risk, err := checkRisk(config.RiskChecker, config.OnRisk, *token, fingerprint)
+	if err != nil {
+		return nil, risk, err
+	}
+	token.touch(time.Now())
+	token.updateNetwork(fingerprint)
+	return token, risk, nil
+}
+
 func (tokens *RefreshTokens) GetMarshaledTokens() []byte {
 	data, err := json.Marshal(tokens.Tokens)
 	if err != nil {
@@ -88,22 +201,30 @@ func (tokens *RefreshTokens) GetMarshaledTokens() []byte {
 	return data
 }
 
-// 仅判断tokens列表中的token是否包含tokenString，不判断这个token是否过期
-func (tokens *RefreshTokens) ContainsTokenString(tokenString string) bool {
-	for _, token := range tokens.Tokens {
-		if token.Token == tokenString {
-			return true
+// Find 以常量时间查找tokenString对应的token，不判断是否过期，没有找到时返回nil。
+// 哈希保存时hasher为RefreshTokenConfig.Hasher；hasher为nil时只能匹配未哈希的token。
+func (tokens *RefreshTokens) Find(tokenString string, hasher *TokenHasher) *RefreshToken {
+	var hash string
+	if hasher != nil {
+		hash = hasher.Hash(tokenString) // 只计算一次
+	}
+	var found *RefreshToken
+	// 比较完所有token后再返回，不从耗时上暴露匹配的位置
+	for i := range tokens.Tokens {
+		if matchesHash(tokens.Tokens[i], tokenString, hash) && found == nil {
+			found = &tokens.Tokens[i]
 		}
 	}
-	return false
+	return found
+}
+
+// 仅判断tokens列表中的token是否包含tokenString，不判断这个token是否过期。哈希保存的token需使用Find
+func (tokens *RefreshTokens) ContainsTokenString(tokenString string) bool {
+	return tokens.Find(tokenString, nil) != nil
 }
 
-// 仅判断tokens列表中的token是否包含tokenString，且判断这个token是否过期
+// 仅判断tokens列表中的token是否包含tokenString，且判断这个token是否过期。哈希保存的token需使用Find
 func (tokens *RefreshTokens) ContainsValidTokenString(tokenString string) bool {
-	for _, token := range tokens.Tokens {
-		if token.Token == tokenString && token.ExpiresAt.After(time.Now()) {
-			return true
-		}
-	}
-	return false
+	token := tokens.Find(tokenString, nil)
+	return token != nil && token.ExpiresAt.After(time.Now())
 }
@@ -0,0 +1,114 @@
+package refresh_token
+
+import (
+	"errors"
+)
+
+// RiskLevel 使用refresh token时的风险等级
+type RiskLevel int
+
+const (
+	RISK_NONE   RiskLevel = iota // 正常
+	RISK_FLAG                    // 可疑，允许使用，但会触发OnRisk，如通知用户
+	RISK_REJECT                  // 拒绝使用，返回ErrRiskRejected
+)
+
+// 风险的原因
+const (
+	RISK_REASON_NEW_NETWORK         = "new_network"         // 在新的网段(或国家、ASN)使用
+	RISK_REASON_BROWSER_CHANGED     = "browser_changed"     // 浏览器与登录时不同
+	RISK_REASON_PLATFORM_CHANGED    = "platform_changed"    // 平台与登录时不同
+	RISK_REASON_CLIENT_KEY_MISMATCH = "client_key_mismatch" // 客户端key与登录时不同
+)
+
+var ErrRiskRejected = errors.New("refresh token rejected by risk check")
+
+// Risk 风险检查的结果
+type Risk struct {
+	Level   RiskLevel
+	Reasons []string
+}
+
+// add 增加一个原因，等级取较高者
+func (risk *Risk) add(level RiskLevel, reason string) {
+	if level == RISK_NONE {
+		return
+	}
+	if level > risk.Level {
+		risk.Level = level
+	}
+	risk.Reasons = append(risk.Reasons, reason)
+}
+
+// RiskChecker 检查token在fingerprint对应的设备上使用是否有风险
+type RiskChecker interface {
+	Check(token RefreshToken, fingerprint Fingerprint) Risk
+}
+
+// RiskCheckerFunc 将函数用作RiskChecker
+type RiskCheckerFunc func(token RefreshToken, fingerprint Fingerprint) Risk
+
+func (f RiskCheckerFunc) Check(token RefreshToken, fingerprint Fingerprint) Risk {
+	return f(token, fingerprint)
+}
+
+// RiskEvent 风险检查结果不为RISK_NONE时，传给OnRisk的事件
+type RiskEvent struct {
+	Token       RefreshToken // 登录时保存的token
+	Fingerprint Fingerprint  // 本次使用的设备
+	Risk        Risk
+}
+
+// DeviceRiskChecker 默认的RiskChecker：客户端key或平台与登录时不同则拒绝，浏览器不同则标记，
+// 网段不同时按NewNetwork处理
+type DeviceRiskChecker struct {
+	NewNetwork RiskLevel              // 在新网段使用时的风险等级
+	Network    func(ip string) string // 将ip映射为网络标识，可替换为国家或ASN查询，为nil时使用IPSubnet
+}
+
+// NewDeviceRiskChecker 新建一个DeviceRiskChecker，newNetwork为在新网段使用时的风险等级
+func NewDeviceRiskChecker(newNetwork RiskLevel) *DeviceRiskChecker {
+	return &DeviceRiskChecker{NewNetwork: newNetwork}
+}
+
+func (c *DeviceRiskChecker) Check(token RefreshToken, fingerprint Fingerprint) Risk {
+	var risk Risk
+	original := token.Fingerprint()
+	if original.ClientKey != "" && !original.SameDevice(Fingerprint{ClientKey: fingerprint.ClientKey}) {
+		risk.add(RISK_REJECT, RISK_REASON_CLIENT_KEY_MISMATCH)
+	}
+	if original.Platform != "" && fingerprint.Platform != "" && original.Platform != fingerprint.Platform {
+		risk.add(RISK_REJECT, RISK_REASON_PLATFORM_CHANGED)
+	}
+	if original.Browser != "" && fingerprint.Browser != "" && original.Browser != fingerprint.Browser {
+		risk.add(RISK_FLAG, RISK_REASON_BROWSER_CHANGED)
+	}
+	if c.network(original) != c.network(fingerprint) {
+		risk.add(c.NewNetwork, RISK_REASON_NEW_NETWORK)
+	}
+	return risk
+}
+
+// network 设备所在网络的标识
+func (c *DeviceRiskChecker) network(fingerprint Fingerprint) string {
+	if c.Network != nil {
+		return c.Network(fingerprint.IP)
+	}
+	return fingerprint.Subnet
+}
+
+// checkRisk 使用checker检查风险，有风险时调用onRisk；RISK_REJECT时返回ErrRiskRejected。
+// checker为nil或fingerprint为空时不检查
+func checkRisk(checker RiskChecker, onRisk func(RiskEvent), token RefreshToken, fingerprint Fingerprint) (Risk, error) {
+	if checker == nil || fingerprint.IsZero() {
+		return Risk{}, nil
+	}
+	risk := checker.Check(token, fingerprint)
+	if risk.Level != RISK_NONE && onRisk != nil {
+		onRisk(RiskEvent{Token: token, Fingerprint: fingerprint, Risk: risk})
+	}
+	if risk.Level >= RISK_REJECT {
+		return risk, ErrRiskRejected
+	}
+	return risk, nil
+}
@@ -0,0 +1,217 @@
+package refresh_token
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session 一个已登录的设备，用于"已登录的设备"页面，不含refresh token本身
+type Session struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	IP         string    `json:"ip"`
+	Platform   string    `json:"platform"`
+	Browser    string    `json:"browser"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"` // 是否是发起本次请求的设备
+}
+
+// SessionManager 基于RefreshTokenStore管理用户的登录设备
+type SessionManager struct {
+	Store       RefreshTokenStore
+	MaxSessions int             // 每个用户最多同时登录的设备数，超出时踢掉最久未使用的设备。0为不限制
+	RiskChecker RiskChecker     // TouchWithFingerprint和CheckRisk时检查设备是否可疑，为nil时不检查
+	OnRisk      func(RiskEvent) // 检查到风险时调用，如通知用户，可为nil
+}
+
+// NewSessionManager 新建一个SessionManager
+func NewSessionManager(store RefreshTokenStore, maxSessions int) *SessionManager {
+	return &SessionManager{
+		Store:       store,
+		MaxSessions: maxSessions,
+	}
+}
+
+// CreateSession 登录时保存新的refresh token，超出MaxSessions时吊销最久未使用的会话，返回被吊销的会话
+func (m *SessionManager) CreateSession(token RefreshToken) (evicted []Session, err error) {
+	if err = m.Store.Create(token); err != nil {
+		return nil, err
+	}
+	if m.MaxSessions <= 0 {
+		return nil, nil
+	}
+	tokens, err := m.Store.ListByUser(token.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) <= m.MaxSessions {
+		return nil, nil
+	}
+	sortByLastUsed(tokens)
+	for _, old := range tokens[m.MaxSessions:] {
+		if m.matches(old, token.Token) {
+			continue
+		}
+		if err := m.revoke(old); err != nil && !errors.Is(err, ErrTokenNotFound) {
+			return evicted, err
+		}
+		evicted = append(evicted, toSession(old, false))
+	}
+	return evicted, nil
+}
+
+// Touch 使用refresh token时调用，更新会话的最后使用时间和ip，并按IdleTimeout顺延有效期
+func (m *SessionManager) Touch(tokenString, ip string) (*RefreshToken, error) {
+	token, err := m.Store.Lookup(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	token.touch(time.Now())
+	token.updateNetwork(Fingerprint{IP: ip, Subnet: IPSubnet(ip)})
+	if err := m.Store.Update(*token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// TouchWithFingerprint 同Touch，另外用RiskChecker检查本次使用的设备，RISK_REJECT时返回ErrRiskRejected
+func (m *SessionManager) TouchWithFingerprint(tokenString string, fingerprint Fingerprint) (*RefreshToken, Risk, error) {
+	token, err := m.Store.Lookup(tokenString)
+	if err != nil {
+		return nil, Risk{}, err
+	}
+	risk, err := m.CheckRisk(*token, fingerprint)
+	if err != nil {
+		return nil, risk, err
+	}
+	token.touch(time.Now())
+	token.updateNetwork(fingerprint)
+	if err := m.Store.Update(*token); err != nil {
+		return nil, risk, err
+	}
+	return token, risk, nil
+}
+
+// CheckRisk 用RiskChecker检查token在fingerprint对应的设备上使用是否有风险，有风险时调用OnRisk，RISK_REJECT时返回ErrRiskRejected。
+// 用于不经过TouchWithFingerprint使用refresh token的场景，如轮换refresh token
+func (m *SessionManager) CheckRisk(token RefreshToken, fingerprint Fingerprint) (Risk, error) {
+	return checkRisk(m.RiskChecker, m.OnRisk, token, fingerprint)
+}
+
+// ListSessions 列出用户所有有效的会话，按最后使用时间从近到远排序。currentTokenString为本次请求的refresh token，用于标记当前设备，可为空
+func (m *SessionManager) ListSessions(userID, currentTokenString string) ([]Session, error) {
+	tokens, err := m.Store.ListByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	sortByLastUsed(tokens)
+	sessions := make([]Session, len(tokens))
+	for i, token := range tokens {
+		sessions[i] = toSession(token, currentTokenString != "" && m.matches(token, currentTokenString))
+	}
+	return sessions, nil
+}
+
+// RenameSession 给设备起名字
+func (m *SessionManager) RenameSession(userID, sessionID, name string) error {
+	token, err := m.findSession(userID, sessionID)
+	if err != nil {
+		return err
+	}
+	token.Name = name
+	return m.Store.Update(*token)
+}
+
+// RevokeSession 吊销一个会话，即让该设备退出登录
+func (m *SessionManager) RevokeSession(userID, sessionID string) error {
+	token, err := m.findSession(userID, sessionID)
+	if err != nil {
+		return err
+	}
+	return m.revoke(*token)
+}
+
+// RevokeOtherSessions 吊销除当前设备外的所有会话，返回吊销的数量
+func (m *SessionManager) RevokeOtherSessions(userID, currentTokenString string) (int, error) {
+	tokens, err := m.Store.ListByUser(userID)
+	if err != nil {
+		return 0, err
+	}
+	revoked := 0
+	for _, token := range tokens {
+		if m.matches(token, currentTokenString) {
+			continue
+		}
+		if err := m.revoke(token); err != nil {
+			if errors.Is(err, ErrTokenNotFound) {
+				continue
+			}
+			return revoked, err
+		}
+		revoked++
+	}
+	return revoked, nil
+}
+
+// storedTokenStore 保存的token与客户端持有的token不同的store，如HashedStore
+type storedTokenStore interface {
+	revokeStored(token RefreshToken) error
+	matches(token RefreshToken, tokenString string) bool
+}
+
+// revoke 吊销ListByUser返回的token
+func (m *SessionManager) revoke(token RefreshToken) error {
+	if store, ok := m.Store.(storedTokenStore); ok {
+		return store.revokeStored(token)
+	}
+	return m.Store.Revoke(token.Token)
+}
+
+// matches 判断ListByUser返回的token是否对应客户端持有的tokenString
+func (m *SessionManager) matches(token RefreshToken, tokenString string) bool {
+	if store, ok := m.Store.(storedTokenStore); ok {
+		return store.matches(token, tokenString)
+	}
+	return token.Token == tokenString
+}
+
+// findSession 根据会话ID查找用户的refresh token
+func (m *SessionManager) findSession(userID, sessionID string) (*RefreshToken, error) {
+	tokens, err := m.Store.ListByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		if token.ID == sessionID {
+			return &token, nil
+		}
+	}
+	return nil, ErrSessionNotFound
+}
+
+// toSession 将refresh token转为Session
+func toSession(token RefreshToken, current bool) Session {
+	return Session{
+		ID:         token.ID,
+		Name:       token.Name,
+		IP:         token.IP,
+		Platform:   token.Platform,
+		Browser:    token.Browser,
+		CreatedAt:  token.CreatedAt,
+		LastUsedAt: token.LastUsedAt,
+		ExpiresAt:  token.ExpiresAt,
+		Current:    current,
+	}
+}
+
+// sortByLastUsed 按最后使用时间从近到远排序
+func sortByLastUsed(tokens []RefreshToken) {
+	sort.SliceStable(tokens, func(i, j int) bool {
+		return tokens[i].LastUsedAt.After(tokens[j].LastUsedAt)
+	})
+}
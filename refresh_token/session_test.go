@@ -0,0 +1,67 @@
+package refresh_token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionManager(t *testing.T) {
+	assertion := assert.New(t)
+	manager := NewSessionManager(NewMemoryStore(), 3)
+	userID := "session-test"
+
+	// 依次登录4台设备，最久未使用的被踢掉
+	tokens := make([]RefreshToken, 4)
+	for i := range tokens {
+		tokens[i] = newUserToken(userID)
+		tokens[i].LastUsedAt = time.Now().Add(time.Duration(i-len(tokens)) * time.Minute)
+	}
+	for _, token := range tokens[:3] {
+		evicted, err := manager.CreateSession(token)
+		require.NoError(t, err)
+		assertion.Empty(evicted)
+	}
+	evicted, err := manager.CreateSession(tokens[3])
+	require.NoError(t, err)
+	require.Len(t, evicted, 1)
+	assertion.Equal(tokens[0].ID, evicted[0].ID)
+
+	// 列出会话，按最后使用时间排序，并标记当前设备
+	sessions, err := manager.ListSessions(userID, tokens[2].Token)
+	require.NoError(t, err)
+	require.Len(t, sessions, 3)
+	assertion.Equal(tokens[3].ID, sessions[0].ID)
+	assertion.True(sessions[1].Current)
+	assertion.False(sessions[0].Current)
+
+	// 使用后成为最近使用的会话
+	touched, err := manager.Touch(tokens[1].Token, "10.0.0.1")
+	require.NoError(t, err)
+	assertion.Equal("10.0.0.1", touched.IP)
+	sessions, err = manager.ListSessions(userID, "")
+	require.NoError(t, err)
+	assertion.Equal(tokens[1].ID, sessions[0].ID)
+
+	// 重命名
+	require.NoError(t, manager.RenameSession(userID, tokens[1].ID, "My Laptop"))
+	sessions, err = manager.ListSessions(userID, "")
+	require.NoError(t, err)
+	assertion.Equal("My Laptop", sessions[0].Name)
+
+	// 吊销单个会话
+	require.NoError(t, manager.RevokeSession(userID, tokens[3].ID))
+	assertion.Equal(ErrSessionNotFound, manager.RevokeSession(userID, tokens[3].ID))
+	assertion.Equal(ErrSessionNotFound, manager.RevokeSession("other-user", tokens[1].ID))
+
+	// 吊销其他所有会话
+	revoked, err := manager.RevokeOtherSessions(userID, tokens[2].Token)
+	require.NoError(t, err)
+	assertion.Equal(1, revoked)
+	sessions, err = manager.ListSessions(userID, tokens[2].Token)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assertion.True(sessions[0].Current)
+}
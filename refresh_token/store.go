@@ -0,0 +1,136 @@
+package refresh_token
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrTokenNotFound = errors.New("refresh token not found")
+	ErrNoUserID      = errors.New("refresh token has no user id")
+)
+
+// RefreshTokenStore 保存refresh token的存储，用于替代存在用户表JsonField中的RefreshTokens。
+// Lookup对已过期的token返回ErrTokenNotFound；ListByUser只返回未过期的token，按过期时间排序。
+// 设置了TokenHash的token以TokenHash为key保存，需要哈希保存时使用HashedStore包装。
+type RefreshTokenStore interface {
+	// Create 保存一个新的refresh token，token.UserID不能为空
+	Create(token RefreshToken) error
+	// Lookup 根据refresh token字符串查找
+	Lookup(tokenString string) (*RefreshToken, error)
+	// Rotate 用newToken替换oldTokenString，oldTokenString不存在(或已被其他请求轮换)时返回ErrTokenNotFound。
+	// newToken沿用原token的ID、CreatedAt、MaxExpiresAt、RememberMe和IdleTimeout，有效期不超过原来的绝对过期时间
+	Rotate(oldTokenString string, newToken RefreshToken) error
+	// Update 更新一个已存在的refresh token(按Token字符串或TokenHash匹配)，如最后使用时间，不存在时返回ErrTokenNotFound
+	Update(token RefreshToken) error
+	// Revoke 删除一个refresh token，不存在时返回ErrTokenNotFound
+	Revoke(tokenString string) error
+	// ListByUser 列出一个用户所有未过期的refresh token
+	ListByUser(userID string) ([]RefreshToken, error)
+	// PurgeExpired 删除一个用户所有已过期的refresh token，返回删除的数量
+	PurgeExpired(userID string) (int, error)
+}
+
+// MemoryStore 基于内存的RefreshTokenStore，用于测试或单机程序
+type MemoryStore struct {
+	mu     sync.Mutex
+	tokens map[string]RefreshToken // key为token字符串，哈希保存时为TokenHash
+}
+
+// NewMemoryStore 新建一个MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tokens: make(map[string]RefreshToken),
+	}
+}
+
+func (s *MemoryStore) Create(token RefreshToken) error {
+	if token.UserID == "" {
+		return ErrNoUserID
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[token.key()] = token
+	return nil
+}
+
+func (s *MemoryStore) Lookup(tokenString string) (*RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.tokens[tokenString]
+	if !ok || token.Expired() {
+		return nil, ErrTokenNotFound
+	}
+	return &token, nil
+}
+
+func (s *MemoryStore) Rotate(oldTokenString string, newToken RefreshToken) error {
+	if newToken.UserID == "" {
+		return ErrNoUserID
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	oldToken, ok := s.tokens[oldTokenString]
+	if !ok || oldToken.Expired() {
+		return ErrTokenNotFound
+	}
+	delete(s.tokens, oldTokenString)
+	newToken = newToken.rotatedFrom(oldToken, time.Now())
+	s.tokens[newToken.key()] = newToken
+	return nil
+}
+
+func (s *MemoryStore) Update(token RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tokens[token.key()]; !ok {
+		return ErrTokenNotFound
+	}
+	s.tokens[token.key()] = token
+	return nil
+}
+
+func (s *MemoryStore) Revoke(tokenString string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tokens[tokenString]; !ok {
+		return ErrTokenNotFound
+	}
+	delete(s.tokens, tokenString)
+	return nil
+}
+
+func (s *MemoryStore) ListByUser(userID string) ([]RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens := make([]RefreshToken, 0)
+	for _, token := range s.tokens {
+		if token.UserID == userID && !token.Expired() {
+			tokens = append(tokens, token)
+		}
+	}
+	sortByExpiry(tokens)
+	return tokens, nil
+}
+
+func (s *MemoryStore) PurgeExpired(userID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	purged := 0
+	for tokenString, token := range s.tokens {
+		if token.UserID == userID && token.Expired() {
+			delete(s.tokens, tokenString)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// sortByExpiry 按过期时间从早到晚排序
+func sortByExpiry(tokens []RefreshToken) {
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].ExpiresAt.Before(tokens[j].ExpiresAt)
+	})
+}
@@ -0,0 +1,174 @@
+package refresh_token
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/adamesong/go-util/random"
+	"github.com/adamesong/go-util/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testConfig = &RefreshTokenConfig{Duration: time.Hour}
+
+func newUserToken(userID string) RefreshToken {
+	token := testConfig.NewRefreshToken("127.0.0.1", "macOS", "Chrome")
+	token.UserID = userID
+	return token
+}
+
+// testStore 对任意RefreshTokenStore实现运行相同的测试
+func testStore(t *testing.T, store RefreshTokenStore) {
+	assertion := assert.New(t)
+	userID := "store-test-" + random.RandomString(8)
+
+	first := newUserToken(userID)
+	require.NoError(t, store.Create(first))
+	second := newUserToken(userID)
+	second.ExpiresAt = second.ExpiresAt.Add(time.Minute)
+	require.NoError(t, store.Create(second))
+	expired := newUserToken(userID)
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	require.NoError(t, store.Create(expired))
+
+	assertion.Equal(ErrNoUserID, store.Create(testConfig.NewRefreshToken("", "", "")))
+
+	// Lookup
+	found, err := store.Lookup(first.Token)
+	require.NoError(t, err)
+	assertion.Equal(userID, found.UserID)
+	assertion.Equal("macOS", found.Platform)
+	_, err = store.Lookup(expired.Token)
+	assertion.Equal(ErrTokenNotFound, err)
+
+	// ListByUser 仅返回未过期的，按过期时间排序
+	tokens, err := store.ListByUser(userID)
+	require.NoError(t, err)
+	require.Len(t, tokens, 2)
+	assertion.Equal(first.ID, tokens[0].ID)
+	assertion.Equal(second.ID, tokens[1].ID)
+
+	// Rotate
+	rotated := newUserToken(userID)
+	require.NoError(t, store.Rotate(first.Token, rotated))
+	_, err = store.Lookup(first.Token)
+	assertion.Equal(ErrTokenNotFound, err)
+	found, err = store.Lookup(rotated.Token)
+	require.NoError(t, err)
+	// 轮换后仍是同一个会话
+	assertion.Equal(first.ID, found.ID)
+	assertion.True(first.CreatedAt.Equal(found.CreatedAt))
+	rotatedToken := rotated.Token
+	rotated = *found
+	rotated.Token = rotatedToken // HashedStore返回的token中只有TokenHash
+	assertion.Equal(ErrTokenNotFound, store.Rotate(first.Token, newUserToken(userID)))
+
+	// Update
+	rotated.Name = "Work Laptop"
+	require.NoError(t, store.Update(rotated))
+	found, err = store.Lookup(rotated.Token)
+	require.NoError(t, err)
+	assertion.Equal("Work Laptop", found.Name)
+	assertion.Equal(ErrTokenNotFound, store.Update(first))
+
+	// Revoke
+	require.NoError(t, store.Revoke(second.Token))
+	assertion.Equal(ErrTokenNotFound, store.Revoke(second.Token))
+	tokens, err = store.ListByUser(userID)
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+	assertion.Equal(rotated.ID, tokens[0].ID)
+
+	// PurgeExpired
+	purged, err := store.PurgeExpired(userID)
+	require.NoError(t, err)
+	assertion.Equal(1, purged)
+	purged, err = store.PurgeExpired(userID)
+	require.NoError(t, err)
+	assertion.Equal(0, purged)
+
+	// 被撤销的token不能通过Update(如Touch、Rename)恢复
+	require.NoError(t, store.Revoke(rotated.Token))
+	rotated.Name = "Revoked Laptop"
+	assertion.Equal(ErrTokenNotFound, store.Update(rotated))
+	_, err = store.Lookup(rotated.Token)
+	assertion.Equal(ErrTokenNotFound, err)
+	tokens, err = store.ListByUser(userID)
+	require.NoError(t, err)
+	assertion.Empty(tokens)
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+	testRotateLifetime(t, NewMemoryStore())
+	testConcurrentRotate(t, NewMemoryStore())
+}
+
+func TestRedisStore(t *testing.T) {
+	redisClient, err := redis.NewRedisClient("localhost:6379", "", 0)
+	require.NoError(t, err, "Failed to initialize redis client for test")
+	defer redisClient.Close()
+
+	testStore(t, NewRedisStore(redisClient))
+	testRotateLifetime(t, NewRedisStore(redisClient))
+	testConcurrentRotate(t, NewRedisStore(redisClient))
+}
+
+// testRotateLifetime 接近绝对过期时间时轮换，不会延长会话的最长有效期
+func testRotateLifetime(t *testing.T, store RefreshTokenStore) {
+	assertion := assert.New(t)
+	config := &RefreshTokenConfig{Policy: &ExpiryPolicy{IdleTimeout: time.Hour, MaxLifetime: 24 * time.Hour}}
+	userID := "rotate-test-" + random.RandomString(8)
+
+	old := config.NewRefreshTokenWithOptions(LoginOptions{IP: "127.0.0.1", RememberMe: true})
+	old.UserID = userID
+	old.CreatedAt = time.Now().Add(-24*time.Hour + time.Minute)
+	old.MaxExpiresAt = time.Now().Add(time.Minute)
+	old.ExpiresAt = old.MaxExpiresAt
+	require.NoError(t, store.Create(old))
+
+	newToken := config.NewRefreshToken("127.0.0.1", "macOS", "Chrome")
+	newToken.UserID = userID
+	require.NoError(t, store.Rotate(old.Token, newToken))
+	found, err := store.Lookup(newToken.Token)
+	require.NoError(t, err)
+	assertion.Equal(old.ID, found.ID)
+	assertion.True(old.CreatedAt.Equal(found.CreatedAt))
+	assertion.True(old.MaxExpiresAt.Equal(found.MaxExpiresAt))
+	assertion.True(found.ExpiresAt.Equal(old.MaxExpiresAt))
+	assertion.True(found.RememberMe)
+	assertion.Equal(time.Hour, found.IdleTimeout)
+
+	require.NoError(t, store.Revoke(newToken.Token))
+}
+
+// testConcurrentRotate 并发轮换同一个token时只有一个请求能成功，用户索引中只留下成功的新token
+func testConcurrentRotate(t *testing.T, store RefreshTokenStore) {
+	userID := "store-test-" + random.RandomString(8)
+	old := newUserToken(userID)
+	require.NoError(t, store.Create(old))
+
+	var wg sync.WaitGroup
+	var succeeded atomic.Int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := store.Rotate(old.Token, newUserToken(userID))
+			if err == nil {
+				succeeded.Add(1)
+				return
+			}
+			assert.Equal(t, ErrTokenNotFound, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), succeeded.Load())
+	tokens, err := store.ListByUser(userID)
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+	assert.Equal(t, old.ID, tokens[0].ID)
+}